@@ -0,0 +1,274 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/validation"
+	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
+	kubeflowv2beta1 "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/typed/kubeflow/v2beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// MPIJob API versions supported by the tracker.
+const (
+	APIVersionV1      = "v1"
+	APIVersionV2beta1 = kubeflow.GroupVersion
+)
+
+var mpiJobV1Resource = schema.GroupVersionResource{
+	Group:    MPIJobGroup,
+	Version:  APIVersionV1,
+	Resource: "mpijobs",
+}
+
+// mpiDistributions maps the v2beta1 MPI implementations to the
+// mpiDistribution values of the v1 API.
+var mpiDistributions = map[kubeflow.MPIImplementation]string{
+	kubeflow.MPIImplementationOpenMPI: "OpenMPI",
+	kubeflow.MPIImplementationIntel:   "IntelMPI",
+}
+
+// PreferredAPIVersion returns the MPIJob API version the tracker uses
+// by default: v2beta1 if served, otherwise v1. It is empty when none
+// of them is served.
+func (c Capabilities) PreferredAPIVersion() string {
+	for _, version := range []string{APIVersionV2beta1, APIVersionV1} {
+		if c.SupportsAPIVersion(version) {
+			return version
+		}
+	}
+	return ""
+}
+
+// APIVersion returns the MPIJob API version the tracker submits jobs to.
+func (t *MPIOperatorTracker) APIVersion() string {
+	if t.apiVersion == "" {
+		return APIVersionV2beta1
+	}
+	return t.apiVersion
+}
+
+// ValidateMPIJob checks the MPIJob like the MPI operator does for the API
+// version: for v2beta1 the defaults of the operator are applied to a copy
+// of the job, which is then checked with the validation package of the
+// operator (like the restart policies, which must be Never or OnFailure).
+// kubeflow.org/v1 jobs are not checked here, they are validated by the v1
+// operator. Jobs with a generated name are checked with a name of the
+// generated length.
+func ValidateMPIJob(job *kubeflow.MPIJob, apiVersion string) error {
+	if apiVersion == APIVersionV1 {
+		return nil
+	}
+	job = job.DeepCopy()
+	kubeflow.SetDefaults_MPIJob(job)
+	if job.Name == "" && job.GenerateName != "" {
+		// the API server appends 5 random characters
+		job.Name = job.GenerateName + "xxxxx"
+	}
+	if errs := validation.ValidateMPIJob(job); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	return nil
+}
+
+// GetClientForAPIVersion returns an MPIJob client which talks to the
+// given MPIJob API version ("" is v2beta1). Independent of the version
+// jobs are handled as v2beta1 objects, so that all functions of this
+// package work with both versions.
+func GetClientForAPIVersion(restConfig *rest.Config, version string) (clientset.Interface, error) {
+	switch version {
+	case "", APIVersionV2beta1:
+		return GetClient(restConfig)
+	case APIVersionV1:
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discovery client: %v", err)
+		}
+		return NewV1Client(dynamicClient, discoveryClient), nil
+	}
+	return nil, fmt.Errorf("unsupported MPIJob API version %q (supported: %s, %s)",
+		version, APIVersionV1, APIVersionV2beta1)
+}
+
+// NewV1Client returns an MPIJob client for the kubeflow.org/v1 API.
+// MPIJobs are converted from v2beta1 when sent to the API server and
+// back to v2beta1 when read. Fields which don't exist in v1
+// (sshAuthMountPath) are dropped. RESTClient() of the returned client
+// is nil.
+func NewV1Client(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface) clientset.Interface {
+	return &v1Clientset{
+		discovery: discoveryClient,
+		kubeflow:  &v1KubeflowClient{dynamicClient: dynamicClient},
+	}
+}
+
+type v1Clientset struct {
+	discovery discovery.DiscoveryInterface
+	kubeflow  *v1KubeflowClient
+}
+
+func (c *v1Clientset) Discovery() discovery.DiscoveryInterface {
+	return c.discovery
+}
+
+func (c *v1Clientset) KubeflowV2beta1() kubeflowv2beta1.KubeflowV2beta1Interface {
+	return c.kubeflow
+}
+
+type v1KubeflowClient struct {
+	dynamicClient dynamic.Interface
+}
+
+func (c *v1KubeflowClient) RESTClient() rest.Interface {
+	return nil
+}
+
+func (c *v1KubeflowClient) MPIJobs(namespace string) kubeflowv2beta1.MPIJobInterface {
+	return &v1MPIJobs{resource: c.dynamicClient.Resource(mpiJobV1Resource).Namespace(namespace)}
+}
+
+// v1MPIJobs implements the v2beta1 MPIJobInterface on top of the v1 API.
+type v1MPIJobs struct {
+	resource dynamic.ResourceInterface
+}
+
+func (c *v1MPIJobs) Create(ctx context.Context, job *kubeflow.MPIJob, opts metav1.CreateOptions) (*kubeflow.MPIJob, error) {
+	object, err := ToV1MPIJob(job)
+	if err != nil {
+		return nil, err
+	}
+	return fromV1Result(c.resource.Create(ctx, object, opts))
+}
+
+func (c *v1MPIJobs) Update(ctx context.Context, job *kubeflow.MPIJob, opts metav1.UpdateOptions) (*kubeflow.MPIJob, error) {
+	object, err := ToV1MPIJob(job)
+	if err != nil {
+		return nil, err
+	}
+	return fromV1Result(c.resource.Update(ctx, object, opts))
+}
+
+func (c *v1MPIJobs) UpdateStatus(ctx context.Context, job *kubeflow.MPIJob, opts metav1.UpdateOptions) (*kubeflow.MPIJob, error) {
+	object, err := ToV1MPIJob(job)
+	if err != nil {
+		return nil, err
+	}
+	return fromV1Result(c.resource.UpdateStatus(ctx, object, opts))
+}
+
+func (c *v1MPIJobs) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.resource.Delete(ctx, name, opts)
+}
+
+func (c *v1MPIJobs) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	return c.resource.DeleteCollection(ctx, opts, listOpts)
+}
+
+func (c *v1MPIJobs) Get(ctx context.Context, name string, opts metav1.GetOptions) (*kubeflow.MPIJob, error) {
+	return fromV1Result(c.resource.Get(ctx, name, opts))
+}
+
+func (c *v1MPIJobs) List(ctx context.Context, opts metav1.ListOptions) (*kubeflow.MPIJobList, error) {
+	objects, err := c.resource.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	list := &kubeflow.MPIJobList{
+		ListMeta: metav1.ListMeta{
+			ResourceVersion: objects.GetResourceVersion(),
+			Continue:        objects.GetContinue(),
+		},
+		Items: make([]kubeflow.MPIJob, 0, len(objects.Items)),
+	}
+	for i := range objects.Items {
+		job, err := FromV1MPIJob(&objects.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *job)
+	}
+	return list, nil
+}
+
+// Watch returns v1 MPIJobs of the events converted to v2beta1.
+func (c *v1MPIJobs) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := c.resource.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if object, ok := event.Object.(*unstructured.Unstructured); ok {
+			if job, err := FromV1MPIJob(object); err == nil {
+				event.Object = job
+			}
+		}
+		return event, true
+	}), nil
+}
+
+func (c *v1MPIJobs) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*kubeflow.MPIJob, error) {
+	return fromV1Result(c.resource.Patch(ctx, name, pt, data, opts, subresources...))
+}
+
+func fromV1Result(object *unstructured.Unstructured, err error) (*kubeflow.MPIJob, error) {
+	if err != nil {
+		return nil, err
+	}
+	return FromV1MPIJob(object)
+}
+
+// ToV1MPIJob converts a v2beta1 MPIJob into a kubeflow.org/v1 MPIJob.
+// The MPI implementation is set as mpiDistribution, sshAuthMountPath
+// does not exist in v1 and is dropped.
+func ToV1MPIJob(job *kubeflow.MPIJob) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert MPIJob %s to %s: %v",
+			job.Name, APIVersionV1, err)
+	}
+	object := &unstructured.Unstructured{Object: content}
+	object.SetAPIVersion(MPIJobGroup + "/" + APIVersionV1)
+	object.SetKind("MPIJob")
+	unstructured.RemoveNestedField(object.Object, "spec", "sshAuthMountPath")
+	unstructured.RemoveNestedField(object.Object, "spec", "mpiImplementation")
+	if distribution, exists := mpiDistributions[job.Spec.MPIImplementation]; exists {
+		err := unstructured.SetNestedField(object.Object, distribution, "spec", "mpiDistribution")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
+// FromV1MPIJob converts a kubeflow.org/v1 MPIJob into a v2beta1 MPIJob.
+// Fields which don't exist in v2beta1 are ignored.
+func FromV1MPIJob(object *unstructured.Unstructured) (*kubeflow.MPIJob, error) {
+	job := &kubeflow.MPIJob{}
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert MPIJob %s from %s: %v",
+			object.GetName(), APIVersionV1, err)
+	}
+	job.APIVersion = kubeflow.SchemeGroupVersion.String()
+	distribution, _, _ := unstructured.NestedString(object.Object, "spec", "mpiDistribution")
+	for implementation, d := range mpiDistributions {
+		if d == distribution {
+			job.Spec.MPIImplementation = implementation
+		}
+	}
+	return job, nil
+}
@@ -0,0 +1,92 @@
+package mpioperatortracker
+
+import (
+	"context"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var _ = Describe("MPIJob API versions", func() {
+
+	It("should prefer v2beta1 over v1", func() {
+		Expect(Capabilities{APIVersions: []string{"v1", "v2beta1"}}.PreferredAPIVersion()).To(Equal(APIVersionV2beta1))
+		Expect(Capabilities{APIVersions: []string{"v1"}}.PreferredAPIVersion()).To(Equal(APIVersionV1))
+		Expect(Capabilities{APIVersions: []string{"v1alpha2"}}.PreferredAPIVersion()).To(Equal(""))
+	})
+
+	It("should convert MPIJobs to v1 and back", func() {
+		spec, err := ConvertJobTemplateToMPIJob(SetMPIImplementationExtension(
+			drmaa2interface.JobTemplate{JobCategory: "mpioperator/mpi-pi:intel", MinSlots: 2}, "Intel"))
+		Expect(err).To(BeNil())
+		job := NewMPIJob(spec)
+		job.Name = "pi"
+
+		object, err := ToV1MPIJob(&job)
+		Expect(err).To(BeNil())
+		Expect(object.GetAPIVersion()).To(Equal("kubeflow.org/v1"))
+		distribution, _, _ := unstructured.NestedString(object.Object, "spec", "mpiDistribution")
+		Expect(distribution).To(Equal("IntelMPI"))
+		_, found, _ := unstructured.NestedFieldNoCopy(object.Object, "spec", "sshAuthMountPath")
+		Expect(found).To(BeFalse())
+		_, found, _ = unstructured.NestedFieldNoCopy(object.Object, "spec", "mpiImplementation")
+		Expect(found).To(BeFalse())
+
+		converted, err := FromV1MPIJob(object)
+		Expect(err).To(BeNil())
+		Expect(converted.Spec.MPIImplementation).To(Equal(kubeflow.MPIImplementationIntel))
+		Expect(equality.Semantic.DeepEqual(converted.Spec.MPIReplicaSpecs, job.Spec.MPIReplicaSpecs)).To(BeTrue())
+		Expect(converted.Spec.SlotsPerWorker).To(Equal(job.Spec.SlotsPerWorker))
+	})
+
+	It("should manage jobs through the v1 API", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+		mpiClient := NewV1Client(dynamicClient, nil)
+
+		spec, err := ConvertJobTemplateToMPIJob(drmaa2interface.JobTemplate{
+			JobCategory: "mpioperator/mpi-pi:openmpi",
+			MinSlots:    2,
+		})
+		Expect(err).To(BeNil())
+		job := NewMPIJob(spec)
+		job.Name = "pi"
+		job.Namespace = "default"
+		_, err = CreateJob(context.Background(), mpiClient, &job, false)
+		Expect(err).To(BeNil())
+
+		object, err := dynamicClient.Resource(mpiJobV1Resource).Namespace("default").Get(
+			context.Background(), "pi", metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		Expect(object.GetAPIVersion()).To(Equal("kubeflow.org/v1"))
+
+		jobs, err := ListJobs(context.Background(), mpiClient, "default")
+		Expect(err).To(BeNil())
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].Name).To(Equal("pi"))
+
+		// status updates of the operator are visible in the job state
+		unstructured.SetNestedSlice(object.Object, []interface{}{
+			map[string]interface{}{"type": string(common.JobSucceeded), "status": "True"},
+		}, "status", "conditions")
+		_, err = dynamicClient.Resource(mpiJobV1Resource).Namespace("default").Update(
+			context.Background(), object, metav1.UpdateOptions{})
+		Expect(err).To(BeNil())
+		state, _, err := GetJobState(context.Background(), mpiClient, "default", "pi")
+		Expect(err).To(BeNil())
+		Expect(state).To(Equal(drmaa2interface.Done))
+
+		Expect(DeleteJob(context.Background(), mpiClient, "default", "pi")).To(Succeed())
+		jobs, err = ListJobs(context.Background(), mpiClient, "default")
+		Expect(err).To(BeNil())
+		Expect(jobs).To(BeEmpty())
+	})
+
+})
@@ -0,0 +1,303 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// LabelArrayJobID is set on all MPIJobs which belong to the same array job.
+const LabelArrayJobID = "mpioperatortracker.drmaa2.io/array-job-id"
+
+// LabelArrayTaskID is set on all MPIJobs which are part of an array job.
+// It contains the task ID.
+const LabelArrayTaskID = "mpioperatortracker.drmaa2.io/array-task-id"
+
+// Environment variables which are set in the launcher and worker containers
+// of each task of an array job.
+const (
+	EnvTaskID       = "TASK_ID"
+	EnvTaskFirst    = "TASK_FIRST"
+	EnvTaskLast     = "TASK_LAST"
+	EnvTaskStepSize = "TASK_STEPSIZE"
+)
+
+// NewArrayJobID returns a new unique array job ID.
+func NewArrayJobID() string {
+	return "drmaa2-mpioperator-array-" + utilrand.String(8)
+}
+
+// ArrayTaskIDs returns all task IDs of an array job definition.
+func ArrayTaskIDs(begin, end, step int) ([]int, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("step must be a positive integer")
+	}
+	if end < begin {
+		return nil, fmt.Errorf("end (%d) must not be smaller than begin (%d)", end, begin)
+	}
+	taskIDs := make([]int, 0, (end-begin)/step+1)
+	for i := begin; i <= end; i += step {
+		taskIDs = append(taskIDs, i)
+	}
+	return taskIDs, nil
+}
+
+// SetArrayTaskEnvironment sets the TASK_ID, TASK_FIRST, TASK_LAST, and
+// TASK_STEPSIZE environment variables in the job template.
+func SetArrayTaskEnvironment(jt drmaa2interface.JobTemplate, taskID, begin, end, step int) drmaa2interface.JobTemplate {
+	env := make(map[string]string, len(jt.JobEnvironment)+4)
+	for k, v := range jt.JobEnvironment {
+		env[k] = v
+	}
+	env[EnvTaskID] = strconv.Itoa(taskID)
+	env[EnvTaskFirst] = strconv.Itoa(begin)
+	env[EnvTaskLast] = strconv.Itoa(end)
+	env[EnvTaskStepSize] = strconv.Itoa(step)
+	jt.JobEnvironment = env
+	return jt
+}
+
+// NewArrayTaskMPIJob creates an MPIJob for a task of an array job. The
+// job name is derived from the array job ID and the task ID.
+func NewArrayTaskMPIJob(spec kubeflow.MPIJobSpec, arrayJobID string, taskID int) kubeflow.MPIJob {
+	job := NewMPIJob(spec)
+	job.GenerateName = ""
+	job.Name = fmt.Sprintf("%s-%d", arrayJobID, taskID)
+	job.Labels[LabelArrayJobID] = arrayJobID
+	job.Labels[LabelArrayTaskID] = strconv.Itoa(taskID)
+	return job
+}
+
+// ArrayTask is a task of an array job.
+type ArrayTask struct {
+	JobID    string                   `json:"jobID"`
+	TaskID   int                      `json:"taskID"`
+	State    drmaa2interface.JobState `json:"state"`
+	SubState string                   `json:"subState"`
+}
+
+// ListArrayJobTasks returns all MPIJobs of the namespace which belong to
+// the given array job.
+func ListArrayJobTasks(ctx context.Context, mpiClient clientset.Interface, namespace, arrayJobID string) ([]kubeflow.MPIJob, error) {
+	selector := labels.SelectorFromSet(labels.Set{LabelArrayJobID: arrayJobID}).String()
+	mpiJobList, err := mpiClient.KubeflowV2beta1().MPIJobs(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mpiJobList.Items, nil
+}
+
+// ArrayTasksFromMPIJobs converts the MPIJobs of an array job into tasks
+// sorted by their task ID. Jobs with an invalid task ID label are skipped.
+func ArrayTasksFromMPIJobs(jobs []kubeflow.MPIJob) []ArrayTask {
+	tasks := make([]ArrayTask, 0, len(jobs))
+	for i := range jobs {
+		taskID, err := strconv.Atoi(jobs[i].Labels[LabelArrayTaskID])
+		if err != nil {
+			continue
+		}
+		state, subState, _ := JobStateFromMPIJob(&jobs[i])
+		tasks = append(tasks, ArrayTask{
+			JobID:    jobs[i].Name,
+			TaskID:   taskID,
+			State:    state,
+			SubState: subState,
+		})
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].TaskID < tasks[j].TaskID
+	})
+	return tasks
+}
+
+func (t *MPIOperatorTracker) addArrayTask(jt drmaa2interface.JobTemplate, arrayJobID string, taskID, begin, end, step int) error {
+	spec, err := ConvertJobTemplateToMPIJob(
+		SetArrayTaskEnvironment(jt, taskID, begin, end, step))
+	if err != nil {
+		return fmt.Errorf("failed to convert DRMAA2 job template to MPI job: %v", err)
+	}
+	job := NewArrayTaskMPIJob(spec, arrayJobID, taskID)
+	t.prepareMPIJob(&job)
+	created, err := CreateJob(context.TODO(), t.clientset, &job, false)
+	if err != nil {
+		return fmt.Errorf("failed to create task %d of array job %s: %v", taskID, arrayJobID, err)
+	}
+	t.metrics.jobSubmitted(&job)
+	t.jobLogger(created.Name, "submit").V(LogLevelOperations).Info("submitted array job task",
+		"arrayJobID", arrayJobID, "taskID", taskID)
+	return t.recordJob(created.Name, jt, arrayJobID, taskID)
+}
+
+// runningArrayTasks returns the amount of submitted tasks which are not
+// yet in an end state.
+func (t *MPIOperatorTracker) runningArrayTasks(ctx context.Context, arrayJobID string) (int, error) {
+	jobs, err := ListArrayJobTasks(ctx, t.clientset, t.Namespace(), arrayJobID)
+	if err != nil {
+		return 0, err
+	}
+	running := 0
+	for _, task := range ArrayTasksFromMPIJobs(jobs) {
+		if !IsEndState(task.State) {
+			running++
+		}
+	}
+	return running, nil
+}
+
+// arrayJobSubmission is the state of an array job whose tasks are
+// submitted in the background.
+type arrayJobSubmission struct {
+	// pending are the IDs of the tasks which are not yet submitted
+	pending []int
+	// err stopped the submission
+	err error
+}
+
+// setArrayJobSubmission records the pending tasks of the array job and the
+// error which stopped their submission. With a job store the pending tasks
+// are stored until all are submitted.
+func (t *MPIOperatorTracker) setArrayJobSubmission(record ArrayJobRecord, err error) {
+	record.PendingTaskIDs = append([]int(nil), record.PendingTaskIDs...)
+	t.arrayJobsMu.Lock()
+	defer t.arrayJobsMu.Unlock()
+	if t.arraySubmissions == nil {
+		t.arraySubmissions = make(map[string]*arrayJobSubmission)
+	}
+	t.arraySubmissions[record.ArrayJobID] = &arrayJobSubmission{
+		pending: record.PendingTaskIDs,
+		err:     err,
+	}
+	if t.store == nil {
+		return
+	}
+	var storeErr error
+	if len(record.PendingTaskIDs) == 0 {
+		storeErr = t.store.DeleteArrayJob(record.Namespace, record.ArrayJobID)
+	} else {
+		storeErr = t.store.PutArrayJob(record)
+	}
+	if storeErr != nil {
+		t.jobLogger(record.ArrayJobID, "store").Error(storeErr, "failed to store pending tasks of array job")
+	}
+}
+
+// PendingArrayTasks returns the IDs of the tasks of an array job which are
+// not yet submitted and the error which stopped their submission. The
+// error is nil while the tasks are submitted in the background. No task
+// IDs are returned when all tasks are submitted or the array job is not
+// known.
+func (t *MPIOperatorTracker) PendingArrayTasks(arrayJobID string) ([]int, error) {
+	t.arrayJobsMu.Lock()
+	submission, exists := t.arraySubmissions[arrayJobID]
+	t.arrayJobsMu.Unlock()
+	if exists {
+		return append([]int(nil), submission.pending...), submission.err
+	}
+	if t.store != nil {
+		record, err := t.store.GetArrayJob(t.Namespace(), arrayJobID)
+		if err == nil {
+			return record.PendingTaskIDs, nil
+		}
+	}
+	return nil, nil
+}
+
+// ResumeArrayJobs continues submitting the pending tasks of the array jobs
+// of the job session which were recorded in the job store, like after a
+// restart of the process or after the submission failed. It returns the
+// IDs of the resumed array jobs.
+func (t *MPIOperatorTracker) ResumeArrayJobs() ([]string, error) {
+	if t.store == nil {
+		return nil, fmt.Errorf("resuming array jobs requires a job store")
+	}
+	records, err := t.store.ListArrayJobs(t.Namespace(), t.jobSessionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list array jobs of job store: %v", err)
+	}
+	var resumed []string
+	for _, record := range records {
+		t.arrayJobsMu.Lock()
+		submission, exists := t.arraySubmissions[record.ArrayJobID]
+		t.arrayJobsMu.Unlock()
+		if exists && submission.err == nil {
+			// still submitted by this tracker
+			continue
+		}
+		t.submitInBackground(record)
+		resumed = append(resumed, record.ArrayJobID)
+	}
+	return resumed, nil
+}
+
+// submitInBackground submits the pending tasks of the array job in a
+// goroutine which is stopped by Close.
+func (t *MPIOperatorTracker) submitInBackground(record ArrayJobRecord) {
+	t.setArrayJobSubmission(record, nil)
+	ctx := t.backgroundContext()
+	t.background.Add(1)
+	go func() {
+		defer t.background.Done()
+		t.submitThrottled(ctx, record)
+	}()
+}
+
+// submitThrottled submits the pending tasks of the array job so that never
+// more than maxParallel tasks of the array job are unfinished. It returns
+// when all tasks are submitted, a submission fails, or ctx is cancelled.
+func (t *MPIOperatorTracker) submitThrottled(ctx context.Context, record ArrayJobRecord) {
+	logger := t.jobLogger(record.ArrayJobID, "submit")
+	jt := record.Template()
+	ticker := time.NewTicker(t.arrayJobPollInterval())
+	defer ticker.Stop()
+	for {
+		running, err := t.runningArrayTasks(ctx, record.ArrayJobID)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error(err, "failed to get tasks of array job")
+			running = record.MaxParallel
+		}
+		logger.V(LogLevelDebug).Info("checked unfinished tasks of array job",
+			"unfinished", running, "pending", len(record.PendingTaskIDs))
+		for free := record.MaxParallel - running; free > 0 && len(record.PendingTaskIDs) > 0; free-- {
+			taskID := record.PendingTaskIDs[0]
+			err := t.addArrayTask(jt, record.ArrayJobID, taskID, record.Begin, record.End, record.Step)
+			if err != nil {
+				logger.Error(err, "stop submitting tasks of array job", "taskID", taskID)
+				t.setArrayJobSubmission(record, err)
+				return
+			}
+			record.PendingTaskIDs = record.PendingTaskIDs[1:]
+			t.setArrayJobSubmission(record, nil)
+		}
+		if len(record.PendingTaskIDs) == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			logger.V(LogLevelOperations).Info("stopped submitting tasks of array job",
+				"pending", len(record.PendingTaskIDs))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *MPIOperatorTracker) arrayJobPollInterval() time.Duration {
+	if t.arrayPollInterval == 0 {
+		return 5 * time.Second
+	}
+	return t.arrayPollInterval
+}
@@ -0,0 +1,204 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// finishArrayTask marks the job as succeeded.
+func finishArrayTask(tracker *MPIOperatorTracker, jobID string) {
+	job, err := DescribeJob(context.Background(), tracker.clientset, "default", jobID)
+	Expect(err).To(BeNil())
+	job.Status.Conditions = append(job.Status.Conditions,
+		common.JobCondition{Type: common.JobSucceeded})
+	_, err = tracker.clientset.KubeflowV2beta1().MPIJobs("default").UpdateStatus(
+		context.Background(), job, metav1.UpdateOptions{})
+	Expect(err).To(BeNil())
+}
+
+var _ = Describe("Array jobs", func() {
+
+	var tracker *MPIOperatorTracker
+	var jt drmaa2interface.JobTemplate
+
+	BeforeEach(func() {
+		tracker = &MPIOperatorTracker{
+			clientset:         fake.NewSimpleClientset(),
+			kubeClient:        k8sfake.NewSimpleClientset(),
+			arrayPollInterval: 10 * time.Millisecond,
+		}
+		jt = drmaa2interface.JobTemplate{
+			JobCategory:    "mpioperator/mpi-pi:intel",
+			MinSlots:       2,
+			JobEnvironment: map[string]string{"MY_VAR": "value"},
+		}
+	})
+
+	It("should calculate the task IDs", func() {
+		taskIDs, err := ArrayTaskIDs(1, 10, 3)
+		Expect(err).To(BeNil())
+		Expect(taskIDs).To(Equal([]int{1, 4, 7, 10}))
+		_, err = ArrayTaskIDs(1, 10, 0)
+		Expect(err).NotTo(BeNil())
+		_, err = ArrayTaskIDs(10, 1, 1)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should submit all tasks with task environment and labels", func() {
+		arrayJobID, err := tracker.AddArrayJob(jt, 1, 5, 2, 0)
+		Expect(err).To(BeNil())
+
+		jobIDs, err := tracker.ListArrayJobs(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(jobIDs).To(HaveLen(3))
+
+		job, err := DescribeJob(context.Background(), tracker.clientset, "default", jobIDs[1])
+		Expect(err).To(BeNil())
+		Expect(job.Labels[LabelArrayJobID]).To(Equal(arrayJobID))
+		Expect(job.Labels[LabelArrayTaskID]).To(Equal("3"))
+		for _, replicaType := range []kubeflow.MPIReplicaType{kubeflow.MPIReplicaTypeLauncher, kubeflow.MPIReplicaTypeWorker} {
+			env := job.Spec.MPIReplicaSpecs[replicaType].Template.Spec.Containers[0].Env
+			Expect(env).To(ContainElement(corev1.EnvVar{Name: EnvTaskID, Value: "3"}))
+			Expect(env).To(ContainElement(corev1.EnvVar{Name: EnvTaskFirst, Value: "1"}))
+			Expect(env).To(ContainElement(corev1.EnvVar{Name: EnvTaskLast, Value: "5"}))
+			Expect(env).To(ContainElement(corev1.EnvVar{Name: EnvTaskStepSize, Value: "2"}))
+			Expect(env).To(ContainElement(corev1.EnvVar{Name: "MY_VAR", Value: "value"}))
+		}
+	})
+
+	It("should list the tasks of an array job from the cluster", func() {
+		arrayJobID, err := tracker.AddArrayJob(jt, 1, 3, 1, 0)
+		Expect(err).To(BeNil())
+		_, err = tracker.AddArrayJob(jt, 1, 2, 1, 0)
+		Expect(err).To(BeNil())
+
+		// a new tracker instance sees the same array job
+		otherTracker := &MPIOperatorTracker{clientset: tracker.clientset}
+		tasks, err := otherTracker.ListArrayJobTasks(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(tasks).To(HaveLen(3))
+		for i, task := range tasks {
+			Expect(task.TaskID).To(Equal(i + 1))
+			Expect(task.State).To(Equal(drmaa2interface.Queued))
+		}
+
+		err = otherTracker.JobControl(tasks[1].JobID, "terminate")
+		Expect(err).To(BeNil())
+		tasks, err = otherTracker.ListArrayJobTasks(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(tasks[1].State).To(Equal(drmaa2interface.Failed))
+
+		_, err = otherTracker.ListArrayJobs("unknown")
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should limit the amount of unfinished tasks to maxParallel", func() {
+		arrayJobID, err := tracker.AddArrayJob(jt, 1, 3, 1, 1)
+		Expect(err).To(BeNil())
+
+		jobIDs, err := tracker.ListArrayJobs(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(jobIDs).To(HaveLen(1))
+		Consistently(func() int {
+			jobIDs, _ := tracker.ListArrayJobs(arrayJobID)
+			return len(jobIDs)
+		}, 100*time.Millisecond, 10*time.Millisecond).Should(Equal(1))
+
+		finishArrayTask(tracker, jobIDs[0])
+		Eventually(func() int {
+			jobIDs, _ := tracker.ListArrayJobs(arrayJobID)
+			return len(jobIDs)
+		}).Should(Equal(2))
+
+		jobIDs, _ = tracker.ListArrayJobs(arrayJobID)
+		finishArrayTask(tracker, jobIDs[1])
+		Eventually(func() int {
+			jobIDs, _ := tracker.ListArrayJobs(arrayJobID)
+			return len(jobIDs)
+		}).Should(Equal(3))
+	})
+
+	It("should stop submitting tasks when the tracker is closed", func() {
+		arrayJobID, err := tracker.AddArrayJob(jt, 1, 3, 1, 1)
+		Expect(err).To(BeNil())
+		pending, err := tracker.PendingArrayTasks(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(pending).To(Equal([]int{2, 3}))
+
+		Expect(tracker.Close()).To(BeNil())
+		jobIDs, err := tracker.ListArrayJobs(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(jobIDs).To(HaveLen(1))
+		finishArrayTask(tracker, jobIDs[0])
+		Consistently(func() int {
+			jobIDs, _ := tracker.ListArrayJobs(arrayJobID)
+			return len(jobIDs)
+		}, 100*time.Millisecond, 10*time.Millisecond).Should(Equal(1))
+		pending, _ = tracker.PendingArrayTasks(arrayJobID)
+		Expect(pending).To(Equal([]int{2, 3}))
+	})
+
+	It("should resume pending tasks recorded in the job store", func() {
+		tempDir, err := os.MkdirTemp("", "arrayjobs")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(tempDir)
+		storePath := filepath.Join(tempDir, "jobs.db")
+		tracker.store, err = OpenJobStore(storePath)
+		Expect(err).To(BeNil())
+
+		arrayJobID, err := tracker.AddArrayJob(jt, 1, 3, 1, 1)
+		Expect(err).To(BeNil())
+		Expect(tracker.Close()).To(BeNil())
+
+		// a new process with the same job store
+		store, err := OpenJobStore(storePath)
+		Expect(err).To(BeNil())
+		restarted := &MPIOperatorTracker{
+			clientset:         tracker.clientset,
+			store:             store,
+			arrayPollInterval: 10 * time.Millisecond,
+		}
+		defer restarted.Close()
+		pending, err := restarted.PendingArrayTasks(arrayJobID)
+		Expect(err).To(BeNil())
+		Expect(pending).To(Equal([]int{2, 3}))
+		resumed, err := restarted.ResumeArrayJobs()
+		Expect(err).To(BeNil())
+		Expect(resumed).To(Equal([]string{arrayJobID}))
+
+		for submitted := 2; submitted <= 3; submitted++ {
+			jobIDs, _ := restarted.ListArrayJobs(arrayJobID)
+			finishArrayTask(restarted, jobIDs[len(jobIDs)-1])
+			Eventually(func() int {
+				jobIDs, _ := restarted.ListArrayJobs(arrayJobID)
+				return len(jobIDs)
+			}).Should(Equal(submitted))
+		}
+		Eventually(func() []int {
+			pending, _ := restarted.PendingArrayTasks(arrayJobID)
+			return pending
+		}).Should(BeEmpty())
+		_, err = store.GetArrayJob("default", arrayJobID)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should reject invalid array job definitions", func() {
+		_, err := tracker.AddArrayJob(jt, 1, 5, 0, 0)
+		Expect(err).NotTo(BeNil())
+		_, err = tracker.AddArrayJob(drmaa2interface.JobTemplate{}, 1, 5, 1, 0)
+		Expect(err).NotTo(BeNil())
+	})
+
+})
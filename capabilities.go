@@ -0,0 +1,193 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// MPIJobGroup is the API group of MPIJobs.
+const MPIJobGroup = kubeflow.GroupName
+
+// Capabilities describe the MPI operator installation of the cluster.
+type Capabilities struct {
+	// APIVersions are the served MPIJob API versions (like v1, v2beta1).
+	APIVersions []string
+	// OperatorNamespace and OperatorDeployment identify the operator
+	// deployment. Empty if it could not be detected (like missing
+	// permissions for listing deployments).
+	OperatorNamespace  string
+	OperatorDeployment string
+	// OperatorImage is the container image of the operator and
+	// OperatorVersion its tag.
+	OperatorImage   string
+	OperatorVersion string
+	// SuspendSupported is true if the MPIJob CRD has runPolicy.suspend.
+	SuspendSupported bool
+	// MPIImplementations are the MPI implementations accepted by the CRD.
+	MPIImplementations []string
+}
+
+// MinAvailableSupported returns true if the operator creates the PodGroup
+// of gang scheduled jobs with the SchedulingPolicy.MinAvailable of the
+// MPIJob, like operator versions v0.4.0 and newer. Older versions use all
+// worker replicas, so elastic jobs only start when MaxSlots workers can be
+// scheduled. Unknown versions (like "latest") return false.
+func (c Capabilities) MinAvailableSupported() bool {
+	var major, minor int
+	version := strings.TrimPrefix(c.OperatorVersion, "v")
+	if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	return major > 0 || minor >= 4
+}
+
+// SupportsAPIVersion returns true if the MPIJob API version is served.
+func (c Capabilities) SupportsAPIVersion(version string) bool {
+	return contains(c.APIVersions, version)
+}
+
+// Capabilities returns the capabilities of the MPI operator detected at
+// tracker creation. It is empty when the detection was skipped.
+func (t *MPIOperatorTracker) Capabilities() Capabilities {
+	return t.capabilities
+}
+
+// OperatorNotFoundError is returned when the MPI operator is not
+// installed in the cluster.
+type OperatorNotFoundError struct {
+	Reason string
+}
+
+func (e *OperatorNotFoundError) Error() string {
+	return fmt.Sprintf("MPI operator not found: %s; install it with InstallMPIOperator() or "+
+		"from https://github.com/kubeflow/mpi-operator", e.Reason)
+}
+
+// DetectCapabilities checks through the discovery API that the MPIJob
+// API kubeflow.org/v2beta1 or kubeflow.org/v1 is served, and detects the operator deployment
+// and its version. The dynamicClient is used for reading the MPIJob CRD;
+// when nil the CRD based capabilities are not detected. An
+// OperatorNotFoundError is returned when the API or the operator
+// deployment is missing.
+func DetectCapabilities(ctx context.Context, kubeClient kubernetes.Interface, dynamicClient dynamic.Interface) (Capabilities, error) {
+	var capabilities Capabilities
+	groups, err := kubeClient.Discovery().ServerGroups()
+	if err != nil {
+		return capabilities, fmt.Errorf("failed to discover API groups: %v", err)
+	}
+	for _, group := range groups.Groups {
+		if group.Name != MPIJobGroup {
+			continue
+		}
+		for _, version := range group.Versions {
+			resources, err := kubeClient.Discovery().ServerResourcesForGroupVersion(version.GroupVersion)
+			if err != nil {
+				continue
+			}
+			for _, resource := range resources.APIResources {
+				if resource.Name == "mpijobs" {
+					capabilities.APIVersions = append(capabilities.APIVersions, version.Version)
+				}
+			}
+		}
+	}
+	if capabilities.PreferredAPIVersion() == "" {
+		return capabilities, &OperatorNotFoundError{
+			Reason: fmt.Sprintf("MPIJob API %s/%s or %s/%s is not served (served versions: %v)",
+				MPIJobGroup, APIVersionV2beta1, MPIJobGroup, APIVersionV1, capabilities.APIVersions),
+		}
+	}
+
+	deployment, err := findOperatorDeployment(ctx, kubeClient)
+	if err != nil {
+		return capabilities, err
+	}
+	if deployment != nil {
+		capabilities.OperatorNamespace = deployment.Namespace
+		capabilities.OperatorDeployment = deployment.Name
+		if containers := deployment.Spec.Template.Spec.Containers; len(containers) > 0 {
+			capabilities.OperatorImage = containers[0].Image
+			capabilities.OperatorVersion = imageTag(containers[0].Image)
+		}
+	}
+
+	if dynamicClient != nil {
+		detectCRDCapabilities(ctx, dynamicClient, &capabilities)
+	}
+	return capabilities, nil
+}
+
+// findOperatorDeployment looks for the operator deployment in the
+// namespace of the embedded manifest and otherwise in all namespaces.
+// When deployments can't be listed due to missing permissions nil is
+// returned without error.
+func findOperatorDeployment(ctx context.Context, kubeClient kubernetes.Interface) (*appsv1.Deployment, error) {
+	deployment, err := kubeClient.AppsV1().Deployments(OperatorNamespace).Get(ctx,
+		OperatorDeploymentName, metav1.GetOptions{})
+	if err == nil {
+		return deployment, nil
+	}
+	if apierrors.IsForbidden(err) {
+		return nil, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get MPI operator deployment: %v", err)
+	}
+	deployments, err := kubeClient.AppsV1().Deployments(metav1.NamespaceAll).List(ctx,
+		metav1.ListOptions{LabelSelector: "app=mpi-operator"})
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list deployments: %v", err)
+	}
+	if len(deployments.Items) == 0 {
+		return nil, &OperatorNotFoundError{Reason: "no MPI operator deployment found"}
+	}
+	return &deployments.Items[0], nil
+}
+
+// imageTag returns the tag of a container image or "latest" if not set.
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// detectCRDCapabilities reads the features of the preferred MPIJob API
+// version from the schema of the CRD.
+func detectCRDCapabilities(ctx context.Context, dynamicClient dynamic.Interface, capabilities *Capabilities) {
+	crd, err := dynamicClient.Resource(crdResource).Get(ctx, MPIJobCRDName, metav1.GetOptions{})
+	if err != nil {
+		// not readable for users without cluster wide permissions
+		return
+	}
+	preferred := capabilities.PreferredAPIVersion()
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["name"] != preferred {
+			continue
+		}
+		spec := []string{"schema", "openAPIV3Schema", "properties", "spec", "properties"}
+		_, found, _ := unstructured.NestedMap(version,
+			append(spec, "runPolicy", "properties", "suspend")...)
+		capabilities.SuspendSupported = found
+		implementations, _, _ := unstructured.NestedStringSlice(version,
+			append(spec, "mpiImplementation", "enum")...)
+		capabilities.MPIImplementations = implementations
+	}
+}
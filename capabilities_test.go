@@ -0,0 +1,92 @@
+package mpioperatortracker
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Capabilities", func() {
+
+	var kubeClient *k8sfake.Clientset
+
+	withMPIJobAPI := func(versions ...string) {
+		for _, version := range versions {
+			kubeClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = append(
+				kubeClient.Discovery().(*fakediscovery.FakeDiscovery).Resources,
+				&metav1.APIResourceList{
+					GroupVersion: "kubeflow.org/" + version,
+					APIResources: []metav1.APIResource{{Name: "mpijobs", Kind: "MPIJob", Namespaced: true}},
+				})
+		}
+	}
+
+	BeforeEach(func() {
+		deployment := newFakeOperatorDeployment(1)
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: "mpi-operator", Image: "registry.local:5000/mpioperator/mpi-operator:0.3.0"},
+		}
+		kubeClient = k8sfake.NewSimpleClientset(deployment)
+	})
+
+	It("should detect API versions, operator version, and CRD features", func() {
+		withMPIJobAPI("v1", "v2beta1")
+		crd, err := DecodeManifest([]byte(mpiOperatorYaml))
+		Expect(err).To(BeNil())
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), crd[1])
+
+		capabilities, err := DetectCapabilities(context.Background(), kubeClient, dynamicClient)
+		Expect(err).To(BeNil())
+		Expect(capabilities.APIVersions).To(ConsistOf("v1", "v2beta1"))
+		Expect(capabilities.OperatorNamespace).To(Equal(OperatorNamespace))
+		Expect(capabilities.OperatorVersion).To(Equal("0.3.0"))
+		Expect(capabilities.SuspendSupported).To(BeFalse())
+		Expect(capabilities.MPIImplementations).To(ConsistOf("OpenMPI", "Intel"))
+	})
+
+	It("should accept an operator serving only the v1 API", func() {
+		withMPIJobAPI("v1")
+		capabilities, err := DetectCapabilities(context.Background(), kubeClient, nil)
+		Expect(err).To(BeNil())
+		Expect(capabilities.PreferredAPIVersion()).To(Equal(APIVersionV1))
+	})
+
+	It("should fail when the MPIJob API is not served", func() {
+		withMPIJobAPI("v1alpha2")
+		_, err := DetectCapabilities(context.Background(), kubeClient, nil)
+		Expect(err).NotTo(BeNil())
+		_, ok := err.(*OperatorNotFoundError)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should fail when the operator deployment is missing", func() {
+		kubeClient = k8sfake.NewSimpleClientset()
+		withMPIJobAPI("v2beta1")
+		_, err := DetectCapabilities(context.Background(), kubeClient, nil)
+		Expect(err).NotTo(BeNil())
+		_, ok := err.(*OperatorNotFoundError)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should return the tag of container images", func() {
+		Expect(imageTag("mpioperator/mpi-operator:0.3.0")).To(Equal("0.3.0"))
+		Expect(imageTag("registry.local:5000/mpi-operator")).To(Equal("latest"))
+		Expect(imageTag("mpi-operator@sha256:abc")).To(Equal("sha256:abc"))
+	})
+
+	It("should detect if MinAvailable is honored for gang scheduling", func() {
+		Expect(Capabilities{OperatorVersion: "v0.4.0"}.MinAvailableSupported()).To(BeTrue())
+		Expect(Capabilities{OperatorVersion: "1.0.1"}.MinAvailableSupported()).To(BeTrue())
+		Expect(Capabilities{OperatorVersion: "0.3.0"}.MinAvailableSupported()).To(BeFalse())
+		Expect(Capabilities{OperatorVersion: "latest"}.MinAvailableSupported()).To(BeFalse())
+		Expect(Capabilities{}.MinAvailableSupported()).To(BeFalse())
+	})
+
+})
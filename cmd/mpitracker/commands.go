@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker"
+	"github.com/dgruber/mpioperatortracker"
+	"github.com/dgruber/mpioperatortracker/server"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/rest"
+)
+
+// jobTracker are the methods of the MPIOperatorTracker used by the CLI.
+type jobTracker interface {
+	AddJob(jt drmaa2interface.JobTemplate) (string, error)
+	JobState(jobID string) (drmaa2interface.JobState, string, error)
+	JobInfo(jobID string) (drmaa2interface.JobInfo, error)
+	JobControl(jobID, action string) error
+	Wait(jobID string, timeout time.Duration, states ...drmaa2interface.JobState) error
+	ListJobInfosFiltered(filter mpioperatortracker.ListJobsFilter) ([]drmaa2interface.JobInfo, string, error)
+	DeleteJob(jobID string) error
+	DeleteJobWithOptions(jobID string, opts mpioperatortracker.DeleteJobOptions) error
+	JobOutput(jobID string, follow bool) (io.ReadCloser, error)
+	Close() error
+}
+
+// arrayJobResumer is implemented by trackers which can continue the
+// submission of array job tasks recorded in the job store.
+type arrayJobResumer interface {
+	ResumeArrayJobs() ([]string, error)
+}
+
+// newTracker creates the tracker for the global options.
+var newTracker = func(params mpioperatortracker.MPIOperatorTrackerParams) (jobTracker, error) {
+	tracker, err := mpioperatortracker.NewMPIOperatorTrackerWithParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
+
+// installMPIOperator installs the MPI operator in the cluster.
+var installMPIOperator = func(restConfig *rest.Config, opts mpioperatortracker.InstallOptions) ([]mpioperatortracker.ApplyResult, error) {
+	return mpioperatortracker.InstallMPIOperatorWithConfig(context.Background(), restConfig, opts)
+}
+
+// withTracker calls f with a tracker created from the global options.
+func (c *cli) withTracker(f func(tracker jobTracker) error) error {
+	tracker, err := newTracker(mpioperatortracker.MPIOperatorTrackerParams{
+		Namespace:      c.namespace,
+		KubeconfigPath: c.kubeconfig,
+		JobSessionName: c.session,
+		JobStorePath:   c.store,
+		APIVersion:     c.apiVersion,
+		Metrics:        c.metrics,
+		Logger:         c.logger(),
+	})
+	if err != nil {
+		return err
+	}
+	defer tracker.Close()
+	return f(tracker)
+}
+
+// logger returns the logger of the tracker which writes to stderr.
+func (c *cli) logger() logr.Logger {
+	return funcr.New(func(prefix, args string) {
+		fmt.Fprintln(c.stderr, args)
+	}, funcr.Options{Verbosity: c.verbosity})
+}
+
+// newFlagSet returns the flag set of a command.
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: mpitracker %s\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseJobID parses the flags of a command which requires a job ID as
+// only argument.
+func (c *cli) parseJobID(flags *flag.FlagSet, args []string) (string, error) {
+	if err := flags.Parse(args); err != nil {
+		return "", usageError{err}
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return "", usageError{fmt.Errorf("%s requires exactly one job ID", flags.Name())}
+	}
+	return flags.Arg(0), nil
+}
+
+func submit(c *cli, args []string) error {
+	flags := c.newFlagSet("submit")
+	file := flags.String("f", "", "job template file (JSON or YAML; - reads from stdin)")
+	image := flags.String("image", "", "container image of the launcher and workers (JobCategory)")
+	slots := flags.Int64("slots", 0, "amount of MPI slots (MinSlots)")
+	name := flags.String("name", "", "job name")
+	queue := flags.String("queue", "", "scheduler queue (SchedulingPolicy.Queue of the MPIJob)")
+	env := keyValues{}
+	flags.Var(env, "env", "environment variable key=value (repeatable)")
+	extensions := keyValues{}
+	flags.Var(extensions, "ext", "job template extension key=value (repeatable)")
+	wait := flags.Bool("wait", false, "wait until the job is finished")
+	if err := flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+
+	var jt drmaa2interface.JobTemplate
+	if *file != "" {
+		var err error
+		jt, err = readJobTemplate(*file, os.Stdin)
+		if err != nil {
+			return err
+		}
+	}
+	if flags.NArg() > 0 {
+		jt.Args = flags.Args()
+	}
+	if *image != "" {
+		jt.JobCategory = *image
+	}
+	if *slots != 0 {
+		jt.MinSlots = *slots
+	}
+	if *name != "" {
+		jt.JobName = *name
+	}
+	if *queue != "" {
+		jt.QueueName = *queue
+	}
+	if len(env) > 0 && jt.JobEnvironment == nil {
+		jt.JobEnvironment = make(map[string]string)
+	}
+	for k, v := range env {
+		jt.JobEnvironment[k] = v
+	}
+	if len(extensions) > 0 && jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	for k, v := range extensions {
+		jt.ExtensionList[k] = v
+	}
+	if jt.JobCategory == "" {
+		return usageError{fmt.Errorf("no image given (-image or jobCategory in the job template)")}
+	}
+
+	return c.withTracker(func(tracker jobTracker) error {
+		jobID, err := tracker.AddJob(jt)
+		if err != nil {
+			return err
+		}
+		if !*wait {
+			if c.output == outputJSON {
+				return printJSON(c.stdout, map[string]string{"id": jobID})
+			}
+			_, err = fmt.Fprintln(c.stdout, jobID)
+			return err
+		}
+		err = tracker.Wait(jobID, drmaa2interface.InfiniteTime,
+			drmaa2interface.Done, drmaa2interface.Failed)
+		if err != nil {
+			return err
+		}
+		return c.printFinalJobInfo(tracker, jobID)
+	})
+}
+
+func status(c *cli, args []string) error {
+	jobID, err := c.parseJobID(c.newFlagSet("status"), args)
+	if err != nil {
+		return err
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		state, subState, err := tracker.JobState(jobID)
+		if err != nil {
+			return err
+		}
+		if c.output == outputJSON {
+			return printJSON(c.stdout, jobOutput{ID: jobID, State: state.String(), SubState: subState})
+		}
+		if subState != "" {
+			_, err = fmt.Fprintf(c.stdout, "%s (%s)\n", state, subState)
+			return err
+		}
+		_, err = fmt.Fprintln(c.stdout, state)
+		return err
+	})
+}
+
+func info(c *cli, args []string) error {
+	jobID, err := c.parseJobID(c.newFlagSet("info"), args)
+	if err != nil {
+		return err
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		return c.printJobInfo(tracker, jobID)
+	})
+}
+
+func (c *cli) printJobInfo(tracker jobTracker, jobID string) error {
+	ji, err := tracker.JobInfo(jobID)
+	if err != nil {
+		return err
+	}
+	return c.printJob(newJobOutput(ji))
+}
+
+// printFinalJobInfo prints the job info of a job which was waited for and
+// returns a jobFailedError when the job has failed.
+func (c *cli) printFinalJobInfo(tracker jobTracker, jobID string) error {
+	ji, err := tracker.JobInfo(jobID)
+	if err != nil {
+		return err
+	}
+	if err := c.printJob(newJobOutput(ji)); err != nil {
+		return err
+	}
+	if ji.State == drmaa2interface.Failed {
+		return jobFailedError{jobID: jobID, subState: ji.SubState}
+	}
+	return nil
+}
+
+func list(c *cli, args []string) error {
+	flags := c.newFlagSet("list")
+	all := flags.Bool("all", false, "include jobs of other job sessions and jobs not created by the tracker")
+	selector := flags.String("selector", "", "Kubernetes label selector")
+	stateName := flags.String("state", "", "only list jobs in this state (like running or done)")
+	if err := flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+	filter := mpioperatortracker.ListJobsFilter{AllJobs: *all, LabelSelector: *selector}
+	if *stateName != "" {
+		state, err := parseJobState(*stateName)
+		if err != nil {
+			return err
+		}
+		jobInfo := drmaa2interface.CreateJobInfo()
+		jobInfo.State = state
+		filter.JobInfo = &jobInfo
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		jobs := []jobOutput{}
+		for {
+			jobInfos, next, err := tracker.ListJobInfosFiltered(filter)
+			if err != nil {
+				return err
+			}
+			for _, ji := range jobInfos {
+				jobs = append(jobs, newJobOutput(ji))
+			}
+			if next == "" {
+				break
+			}
+			filter.Continue = next
+		}
+		return c.printJobs(jobs)
+	})
+}
+
+func waitJob(c *cli, args []string) error {
+	flags := c.newFlagSet("wait")
+	timeout := flags.Duration("timeout", 0, "max. time to wait (0 waits forever)")
+	var stateNames stringList
+	flags.Var(&stateNames, "state", "job state to wait for (repeatable; default done and failed)")
+	jobID, err := c.parseJobID(flags, args)
+	if err != nil {
+		return err
+	}
+	states := []drmaa2interface.JobState{drmaa2interface.Done, drmaa2interface.Failed}
+	if len(stateNames) > 0 {
+		states = states[:0]
+		for _, name := range stateNames {
+			state, err := parseJobState(name)
+			if err != nil {
+				return err
+			}
+			states = append(states, state)
+		}
+	}
+	if *timeout == 0 {
+		*timeout = drmaa2interface.InfiniteTime
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		if err := tracker.Wait(jobID, *timeout, states...); err != nil {
+			return err
+		}
+		return c.printFinalJobInfo(tracker, jobID)
+	})
+}
+
+func logs(c *cli, args []string) error {
+	flags := c.newFlagSet("logs")
+	follow := flags.Bool("f", false, "follow the output until the launcher terminates")
+	jobID, err := c.parseJobID(flags, args)
+	if err != nil {
+		return err
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		output, err := tracker.JobOutput(jobID, *follow)
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+		_, err = io.Copy(c.stdout, output)
+		return err
+	})
+}
+
+func terminate(c *cli, args []string) error {
+	jobID, err := c.parseJobID(c.newFlagSet("terminate"), args)
+	if err != nil {
+		return err
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		return tracker.JobControl(jobID, jobtracker.JobControlTerminate)
+	})
+}
+
+func deleteJob(c *cli, args []string) error {
+	flags := c.newFlagSet("delete")
+	waitForPods := flags.Bool("wait", false, "wait until the pods of the job are gone")
+	jobID, err := c.parseJobID(flags, args)
+	if err != nil {
+		return err
+	}
+	opts := mpioperatortracker.DefaultDeleteJobOptions
+	opts.WaitForPods = *waitForPods
+	return c.withTracker(func(tracker jobTracker) error {
+		return tracker.DeleteJobWithOptions(jobID, opts)
+	})
+}
+
+func install(c *cli, args []string) error {
+	flags := c.newFlagSet("install")
+	var opts mpioperatortracker.InstallOptions
+	flags.StringVar(&opts.ManifestPath, "manifest", "", "MPI operator manifest used instead of the embedded one")
+	flags.StringVar(&opts.Image, "image", "", "image of the MPI operator")
+	flags.StringVar(&opts.Namespace, "operator-namespace", "", "namespace of the MPI operator")
+	flags.StringVar(&opts.GangScheduling, "gang-scheduling", "", "gang scheduler of the MPI operator (like volcano)")
+	var extraArgs stringList
+	flags.Var(&extraArgs, "arg", "additional argument of the MPI operator (repeatable)")
+	flags.DurationVar(&opts.ReadyTimeout, "timeout", mpioperatortracker.DefaultReadyTimeout, "max. time to wait for the operator")
+	flags.BoolVar(&opts.SkipWait, "no-wait", false, "don't wait until the operator is ready")
+	if err := flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return usageError{fmt.Errorf("install takes no arguments")}
+	}
+	opts.ExtraArgs = extraArgs
+
+	kubeconfig := c.kubeconfig
+	if kubeconfig == "" {
+		kubeconfig = os.Getenv("KUBECONFIG")
+		if kubeconfig == "" {
+			kubeconfig = os.Getenv("HOME") + "/.kube/config"
+		}
+	}
+	restConfig, err := mpioperatortracker.NewRestConfig(kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to create REST config: %v", err)
+	}
+	results, err := installMPIOperator(restConfig, opts)
+	if c.output == outputJSON {
+		type result struct {
+			Kind      string `json:"kind"`
+			Namespace string `json:"namespace,omitempty"`
+			Name      string `json:"name"`
+			Action    string `json:"action"`
+			Error     string `json:"error,omitempty"`
+		}
+		printed := make([]result, 0, len(results))
+		for _, r := range results {
+			p := result{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name, Action: r.Action}
+			if r.Err != nil {
+				p.Error = r.Err.Error()
+			}
+			printed = append(printed, p)
+		}
+		if err := printJSON(c.stdout, printed); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			fmt.Fprintln(c.stdout, r.String())
+		}
+	}
+	return err
+}
+
+func serve(c *cli, args []string) error {
+	flags := c.newFlagSet("serve")
+	listen := flags.String("listen", "127.0.0.1:8080", "address the server listens on")
+	var opts server.Options
+	flags.StringVar(&opts.Token, "token", os.Getenv("MPITRACKER_TOKEN"),
+		"bearer token required from clients (default $MPITRACKER_TOKEN)")
+	flags.BoolVar(&opts.Insecure, "insecure", false, "serve requests without a token")
+	allowExtensions := flags.String("allow-extensions", "",
+		"comma separated job template extensions clients may set in addition to the defaults,\n"+
+			"a trailing * matches a prefix (like runAsUser or workerPodTemplatePatch)")
+	flags.BoolVar(&opts.AllowStageInFiles, "allow-stage-in", false,
+		"allow clients to mount persistent volume claims and config maps with stageInFiles")
+	flags.DurationVar(&opts.MaxWait, "max-wait", 5*time.Minute, "max. time a wait request blocks")
+	withMetrics := flags.Bool("metrics", false, "serve Prometheus metrics at /metrics")
+	if err := flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return usageError{fmt.Errorf("serve takes no arguments")}
+	}
+	if opts.Token == "" && !opts.Insecure {
+		return usageError{fmt.Errorf("serve requires a token (-token or $MPITRACKER_TOKEN) or -insecure")}
+	}
+	if *allowExtensions != "" {
+		opts.AllowedExtensions = append(append([]string{}, server.DefaultAllowedExtensions...),
+			strings.Split(*allowExtensions, ",")...)
+	}
+	registry := prometheus.NewRegistry()
+	if *withMetrics {
+		metrics, err := mpioperatortracker.NewMetrics(registry)
+		if err != nil {
+			return fmt.Errorf("failed to create metrics: %v", err)
+		}
+		c.metrics = metrics
+	}
+	return c.withTracker(func(tracker jobTracker) error {
+		// continue submitting array job tasks of a previous server
+		if resumer, ok := tracker.(arrayJobResumer); ok && c.store != "" {
+			resumed, err := resumer.ResumeArrayJobs()
+			if err != nil {
+				return err
+			}
+			for _, arrayJobID := range resumed {
+				fmt.Fprintf(c.stderr, "resumed array job %s\n", arrayJobID)
+			}
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		var handler http.Handler = server.New(tracker, opts)
+		if *withMetrics {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			mux.Handle("/", handler)
+			handler = mux
+		}
+		httpServer := &http.Server{Addr: *listen, Handler: handler}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			httpServer.Shutdown(shutdownCtx)
+		}()
+		fmt.Fprintf(c.stderr, "listening on %s\n", *listen)
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+}
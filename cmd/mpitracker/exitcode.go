@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/mpioperatortracker"
+)
+
+// exitCodeJobFailed is the exit code of submit -wait and wait when the job
+// has failed. It is outside of the range of the DRMAA2 error IDs.
+const exitCodeJobFailed = 100
+
+// usageError is returned for invalid command lines.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// jobFailedError is returned when a job which was waited for has failed.
+type jobFailedError struct {
+	jobID    string
+	subState string
+}
+
+func (e jobFailedError) Error() string {
+	if e.subState != "" {
+		return fmt.Sprintf("job %s failed (%s)", e.jobID, e.subState)
+	}
+	return fmt.Sprintf("job %s failed", e.jobID)
+}
+
+// exitCode maps an error to the exit code of the command. Errors of the
+// tracker are mapped to their DRMAA2 error ID.
+func exitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	var usage usageError
+	var jobFailed jobFailedError
+	switch {
+	case errors.As(err, &usage):
+		return int(drmaa2interface.InvalidArgument)
+	case errors.As(err, &jobFailed):
+		return exitCodeJobFailed
+	}
+	return int(mpioperatortracker.ErrorID(err))
+}
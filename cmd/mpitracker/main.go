@@ -0,0 +1,127 @@
+// Command mpitracker submits and manages MPI jobs through the MPI operator
+// using the DRMAA2 job template conversion of the mpioperatortracker
+// package.
+//
+//	mpitracker [global flags] <command> [flags] [arguments]
+//
+// Commands are submit, status, info, list, wait, logs, terminate, delete,
+// install, and serve, which exposes the jobs through a REST API. The exit
+// code is the DRMAA2 error ID of the failure (0 is success, see
+// drmaa2interface.ErrorID).
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dgruber/mpioperatortracker"
+)
+
+// globalOptions are the flags given before the command.
+type globalOptions struct {
+	kubeconfig string
+	namespace  string
+	session    string
+	store      string
+	apiVersion string
+	output     string
+	verbosity  int
+}
+
+// command is a subcommand of the CLI.
+type command struct {
+	usage       string
+	description string
+	run         func(cli *cli, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"submit": {"submit [flags] [command args]",
+			"submits a job defined by a job template file (-f) and flags", submit},
+		"status": {"status <job ID>", "prints the state and sub-state of a job", status},
+		"info":   {"info <job ID>", "prints the job info of a job", info},
+		"list":   {"list [flags]", "lists the jobs of the job session", list},
+		"wait":   {"wait [flags] <job ID>", "waits until a job is in one of the given states", waitJob},
+		"logs":   {"logs [flags] <job ID>", "prints the output of the launcher of a job", logs},
+		"terminate": {"terminate <job ID>",
+			"stops a job; its state is kept until it is deleted", terminate},
+		"delete":  {"delete [flags] <job ID>", "removes a finished job", deleteJob},
+		"install": {"install [flags]", "installs or upgrades the MPI operator", install},
+		"serve":   {"serve [flags]", "serves the REST API (see server/openapi.yaml)", serve},
+	}
+}
+
+// cli holds the global options and the output streams of a run.
+type cli struct {
+	globalOptions
+	// metrics are passed to the tracker; nil if not enabled
+	metrics *mpioperatortracker.Metrics
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+	flags := flag.NewFlagSet("mpitracker", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.kubeconfig, "kubeconfig", "", "path to the kubeconfig (default $KUBECONFIG or $HOME/.kube/config)")
+	flags.StringVar(&c.namespace, "namespace", "", "namespace of the jobs (default \"default\")")
+	flags.StringVar(&c.session, "session", "", "job session name; only jobs of the session are listed")
+	flags.StringVar(&c.store, "store", "", "path to the local job store database")
+	flags.StringVar(&c.apiVersion, "api-version", "", "MPIJob API version (v1 or v2beta1; default is detected)")
+	flags.StringVar(&c.output, "o", outputTable, "output format (table or json)")
+	flags.IntVar(&c.verbosity, "v", 0, "log verbosity (1 logs job operations, 2 details)")
+	flags.Usage = func() { printUsage(flags) }
+	if err := flags.Parse(args); err != nil {
+		return c.fail(usageError{err})
+	}
+	if flags.NArg() == 0 {
+		printUsage(flags)
+		return exitCode(usageError{fmt.Errorf("no command given")})
+	}
+	if c.output != outputTable && c.output != outputJSON {
+		return c.fail(usageError{fmt.Errorf("unknown output format %q", c.output)})
+	}
+	cmd, exists := commands[flags.Arg(0)]
+	if !exists {
+		printUsage(flags)
+		return c.fail(usageError{fmt.Errorf("unknown command %q", flags.Arg(0))})
+	}
+	return c.fail(cmd.run(c, flags.Args()[1:]))
+}
+
+// fail prints the error and returns its exit code.
+func (c *cli) fail(err error) int {
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(c.stderr, "error: %s\n", strings.TrimSpace(err.Error()))
+	}
+	return exitCode(err)
+}
+
+func printUsage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintf(out, "usage: mpitracker [global flags] <command> [flags] [arguments]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-50s %s\n", commands[name].usage, commands[name].description)
+	}
+	fmt.Fprintf(out, "\nglobal flags:\n")
+	flags.PrintDefaults()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/mpioperatortracker"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeTracker records the calls of the CLI.
+type fakeTracker struct {
+	submitted []drmaa2interface.JobTemplate
+	jobs      map[string]drmaa2interface.JobInfo
+	waitErr   error
+}
+
+func (f *fakeTracker) AddJob(jt drmaa2interface.JobTemplate) (string, error) {
+	f.submitted = append(f.submitted, jt)
+	return "job-1", nil
+}
+
+func (f *fakeTracker) JobState(jobID string) (drmaa2interface.JobState, string, error) {
+	ji, err := f.JobInfo(jobID)
+	return ji.State, ji.SubState, err
+}
+
+func (f *fakeTracker) JobInfo(jobID string) (drmaa2interface.JobInfo, error) {
+	ji, exists := f.jobs[jobID]
+	if !exists {
+		return ji, drmaa2interface.Error{ID: drmaa2interface.InvalidArgument, Message: "job not found"}
+	}
+	return ji, nil
+}
+
+func (f *fakeTracker) JobControl(jobID, action string) error {
+	return nil
+}
+
+func (f *fakeTracker) Wait(jobID string, timeout time.Duration, states ...drmaa2interface.JobState) error {
+	return f.waitErr
+}
+
+func (f *fakeTracker) ListJobInfosFiltered(filter mpioperatortracker.ListJobsFilter) ([]drmaa2interface.JobInfo, string, error) {
+	var jobInfos []drmaa2interface.JobInfo
+	for _, ji := range f.jobs {
+		if filter.JobInfo == nil || filter.JobInfo.State == ji.State {
+			jobInfos = append(jobInfos, ji)
+		}
+	}
+	return jobInfos, "", nil
+}
+
+func (f *fakeTracker) DeleteJob(jobID string) error {
+	return f.DeleteJobWithOptions(jobID, mpioperatortracker.DefaultDeleteJobOptions)
+}
+
+func (f *fakeTracker) DeleteJobWithOptions(jobID string, opts mpioperatortracker.DeleteJobOptions) error {
+	ji, _ := f.JobInfo(jobID)
+	if !mpioperatortracker.IsEndState(ji.State) {
+		return &mpioperatortracker.JobNotInEndStateError{JobID: jobID, State: ji.State}
+	}
+	return nil
+}
+
+func (f *fakeTracker) JobOutput(jobID string, follow bool) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("pi is 3.14\n")), nil
+}
+
+func (f *fakeTracker) Close() error {
+	return nil
+}
+
+var _ = Describe("Mpitracker", func() {
+
+	var tracker *fakeTracker
+	var stdout, stderr *bytes.Buffer
+
+	mpitracker := func(args ...string) int {
+		return run(args, stdout, stderr)
+	}
+
+	BeforeEach(func() {
+		ji := drmaa2interface.CreateJobInfo()
+		ji.ID = "job-1"
+		ji.State = drmaa2interface.Running
+		ji.Slots = 4
+		tracker = &fakeTracker{jobs: map[string]drmaa2interface.JobInfo{"job-1": ji}}
+		newTracker = func(params mpioperatortracker.MPIOperatorTrackerParams) (jobTracker, error) {
+			return tracker, nil
+		}
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	})
+
+	It("should submit a job template file overridden by flags", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "job.yaml")
+		Expect(os.WriteFile(path, []byte(`jobCategory: mpioperator/mpi-pi:openmpi
+minSlots: 2
+jobEnvironment:
+  A: file
+extensions:
+  mpiImplementation: OpenMPI
+`), 0600)).To(Succeed())
+		Expect(mpitracker("submit", "-f", path, "-slots", "4", "-env", "B=flag",
+			"-ext", "slotsPerWorker=2", "mpirun", "-n", "4", "pi")).To(Equal(0))
+		Expect(stdout.String()).To(Equal("job-1\n"))
+		Expect(tracker.submitted).To(HaveLen(1))
+		jt := tracker.submitted[0]
+		Expect(jt.JobCategory).To(Equal("mpioperator/mpi-pi:openmpi"))
+		Expect(jt.MinSlots).To(BeNumerically("==", 4))
+		Expect(jt.Args).To(Equal([]string{"mpirun", "-n", "4", "pi"}))
+		Expect(jt.JobEnvironment).To(Equal(map[string]string{"A": "file", "B": "flag"}))
+		Expect(jt.ExtensionList).To(Equal(map[string]string{
+			"mpiImplementation": "OpenMPI", "slotsPerWorker": "2"}))
+	})
+
+	It("should print jobs as table or JSON", func() {
+		Expect(mpitracker("list")).To(Equal(0))
+		Expect(stdout.String()).To(HavePrefix("ID"))
+		Expect(stdout.String()).To(ContainSubstring("job-1"))
+
+		stdout.Reset()
+		Expect(mpitracker("-o", "json", "info", "job-1")).To(Equal(0))
+		Expect(stdout.String()).To(ContainSubstring(`"state": "Running"`))
+		Expect(stdout.String()).NotTo(ContainSubstring("exitStatus"))
+
+		stdout.Reset()
+		Expect(mpitracker("logs", "job-1")).To(Equal(0))
+		Expect(stdout.String()).To(Equal("pi is 3.14\n"))
+	})
+
+	It("should return DRMAA2 error IDs as exit codes", func() {
+		Expect(mpitracker("unknown")).To(Equal(int(drmaa2interface.InvalidArgument)))
+		Expect(mpitracker("submit")).To(Equal(int(drmaa2interface.InvalidArgument)))
+		Expect(mpitracker("list", "-state", "sleeping")).To(Equal(int(drmaa2interface.InvalidArgument)))
+		Expect(mpitracker("delete", "job-1")).To(Equal(int(drmaa2interface.InvalidState)))
+		Expect(mpitracker("status", "job-2")).To(Equal(int(drmaa2interface.InvalidArgument)))
+		tracker.waitErr = drmaa2interface.Error{ID: drmaa2interface.Timeout, Message: "timeout while waiting for job state"}
+		Expect(mpitracker("wait", "-timeout", "1s", "job-1")).To(Equal(int(drmaa2interface.Timeout)))
+		Expect(stderr.String()).To(ContainSubstring("error: timeout"))
+		Expect(exitCode(drmaa2interface.Error{ID: drmaa2interface.TryLater})).To(Equal(int(drmaa2interface.TryLater)))
+		Expect(exitCode(errors.New("job not found"))).To(Equal(int(drmaa2interface.Internal)))
+	})
+
+	It("should return non-zero when the job waited for has failed", func() {
+		ji := tracker.jobs["job-1"]
+		ji.State = drmaa2interface.Failed
+		tracker.jobs["job-1"] = ji
+		Expect(mpitracker("wait", "job-1")).To(Equal(exitCodeJobFailed))
+		Expect(stdout.String()).To(ContainSubstring("job-1"))
+		Expect(stderr.String()).To(ContainSubstring("error: job job-1 failed"))
+		Expect(mpitracker("submit", "-image", "mpioperator/mpi-pi:openmpi", "-wait", "pi")).To(Equal(exitCodeJobFailed))
+
+		ji.State = drmaa2interface.Done
+		tracker.jobs["job-1"] = ji
+		Expect(mpitracker("wait", "job-1")).To(Equal(0))
+	})
+
+	It("should refuse to serve without a token unless insecure", func() {
+		os.Unsetenv("MPITRACKER_TOKEN")
+		Expect(mpitracker("serve")).To(Equal(int(drmaa2interface.InvalidArgument)))
+		Expect(stderr.String()).To(ContainSubstring("-insecure"))
+	})
+
+})
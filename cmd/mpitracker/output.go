@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+)
+
+// Output formats.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// jobStates are all DRMAA2 job states by their names.
+var jobStates = func() map[string]drmaa2interface.JobState {
+	states := make(map[string]drmaa2interface.JobState)
+	for _, state := range []drmaa2interface.JobState{
+		drmaa2interface.Undetermined, drmaa2interface.Queued, drmaa2interface.QueuedHeld,
+		drmaa2interface.Running, drmaa2interface.Suspended, drmaa2interface.Requeued,
+		drmaa2interface.RequeuedHeld, drmaa2interface.Done, drmaa2interface.Failed,
+	} {
+		states[strings.ToLower(state.String())] = state
+	}
+	return states
+}()
+
+func parseJobState(name string) (drmaa2interface.JobState, error) {
+	state, exists := jobStates[strings.ToLower(name)]
+	if !exists {
+		return drmaa2interface.Unset, usageError{fmt.Errorf("unknown job state %q", name)}
+	}
+	return state, nil
+}
+
+// jobOutput is the printed representation of a job. Contrary to the
+// JobInfo JSON the state is a name and unset values are omitted.
+type jobOutput struct {
+	ID                string     `json:"id"`
+	State             string     `json:"state"`
+	SubState          string     `json:"subState,omitempty"`
+	ExitStatus        *int       `json:"exitStatus,omitempty"`
+	Slots             int64      `json:"slots,omitempty"`
+	QueueName         string     `json:"queueName,omitempty"`
+	JobOwner          string     `json:"jobOwner,omitempty"`
+	AllocatedMachines []string   `json:"allocatedMachines,omitempty"`
+	SubmissionTime    *time.Time `json:"submissionTime,omitempty"`
+	DispatchTime      *time.Time `json:"dispatchTime,omitempty"`
+	FinishTime        *time.Time `json:"finishTime,omitempty"`
+	WallclockTime     string     `json:"wallclockTime,omitempty"`
+}
+
+func newJobOutput(ji drmaa2interface.JobInfo) jobOutput {
+	job := jobOutput{
+		ID:                ji.ID,
+		State:             ji.State.String(),
+		SubState:          ji.SubState,
+		QueueName:         ji.QueueName,
+		JobOwner:          ji.JobOwner,
+		AllocatedMachines: ji.AllocatedMachines,
+	}
+	if ji.ExitStatus != drmaa2interface.UnsetNum {
+		exitStatus := ji.ExitStatus
+		job.ExitStatus = &exitStatus
+	}
+	if ji.Slots > 0 {
+		job.Slots = ji.Slots
+	}
+	for _, t := range []struct {
+		value  time.Time
+		output **time.Time
+	}{
+		{ji.SubmissionTime, &job.SubmissionTime},
+		{ji.DispatchTime, &job.DispatchTime},
+		{ji.FinishTime, &job.FinishTime},
+	} {
+		if !t.value.IsZero() {
+			value := t.value
+			*t.output = &value
+		}
+	}
+	if ji.WallclockTime > 0 {
+		job.WallclockTime = ji.WallclockTime.Round(time.Second).String()
+	}
+	return job
+}
+
+// printJSON prints the value as indented JSON.
+func printJSON(out io.Writer, value interface{}) error {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(encoded))
+	return err
+}
+
+// printJobs prints the jobs in the output format.
+func (c *cli) printJobs(jobs []jobOutput) error {
+	if c.output == outputJSON {
+		return printJSON(c.stdout, jobs)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tSUBSTATE\tSLOTS\tOWNER\tSUBMITTED")
+	for _, job := range jobs {
+		submitted := ""
+		if job.SubmissionTime != nil {
+			submitted = job.SubmissionTime.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", job.ID, job.State, job.SubState,
+			job.Slots, job.JobOwner, submitted)
+	}
+	return w.Flush()
+}
+
+// printJob prints the details of one job in the output format.
+func (c *cli) printJob(job jobOutput) error {
+	if c.output == outputJSON {
+		return printJSON(c.stdout, job)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	row := func(name string, value interface{}) {
+		fmt.Fprintf(w, "%s:\t%v\n", name, value)
+	}
+	row("ID", job.ID)
+	row("State", job.State)
+	if job.SubState != "" {
+		row("SubState", job.SubState)
+	}
+	if job.ExitStatus != nil {
+		row("ExitStatus", *job.ExitStatus)
+	}
+	row("Slots", job.Slots)
+	if job.QueueName != "" {
+		row("QueueName", job.QueueName)
+	}
+	if job.JobOwner != "" {
+		row("JobOwner", job.JobOwner)
+	}
+	if len(job.AllocatedMachines) > 0 {
+		row("AllocatedMachines", strings.Join(job.AllocatedMachines, ","))
+	}
+	for _, t := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"SubmissionTime", job.SubmissionTime},
+		{"DispatchTime", job.DispatchTime},
+		{"FinishTime", job.FinishTime},
+	} {
+		if t.value != nil {
+			row(t.name, t.value.Local().Format(time.RFC3339))
+		}
+	}
+	if job.WallclockTime != "" {
+		row("WallclockTime", job.WallclockTime)
+	}
+	return w.Flush()
+}
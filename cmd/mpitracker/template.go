@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dgruber/drmaa2interface"
+	"sigs.k8s.io/yaml"
+)
+
+// templateFile is the format of job template files: the JSON fields of
+// drmaa2interface.JobTemplate and the extensions, like
+//
+//	jobCategory: mpioperator/mpi-pi:openmpi
+//	args: [mpirun, -n, "4", /home/mpiuser/pi]
+//	minSlots: 4
+//	extensions:
+//	  mpiImplementation: OpenMPI
+type templateFile struct {
+	drmaa2interface.JobTemplate
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+// readJobTemplate reads a job template file in JSON or YAML format. The
+// path - reads from stdin.
+func readJobTemplate(path string, stdin io.Reader) (drmaa2interface.JobTemplate, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return drmaa2interface.JobTemplate{}, fmt.Errorf("failed to read job template: %v", err)
+	}
+	return parseJobTemplate(content)
+}
+
+func parseJobTemplate(content []byte) (drmaa2interface.JobTemplate, error) {
+	var file templateFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return drmaa2interface.JobTemplate{}, usageError{fmt.Errorf("invalid job template: %v", err)}
+	}
+	jt := file.JobTemplate
+	jt.ExtensionList = file.Extensions
+	return jt, nil
+}
+
+// keyValues is a repeatable flag of key=value pairs.
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	pairs := make([]string, 0, len(kv))
+	for k, v := range kv {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (kv keyValues) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected key=value but got %q", value)
+	}
+	kv[value[:i]] = value[i+1:]
+	return nil
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -26,3 +27,14 @@ func GetClient(restConfig *rest.Config) (clientset.Interface, error) {
 	}
 	return mpiClient, nil
 }
+
+func GetKubernetesClient(restConfig *rest.Config) (kubernetes.Interface, error) {
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	if kubeClient == nil {
+		return nil, fmt.Errorf("failed to create kubernetes client")
+	}
+	return kubeClient, nil
+}
@@ -2,22 +2,96 @@ package mpioperatortracker
 
 import (
 	"context"
+	"os/user"
+	"strings"
 	"time"
 
 	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
 	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+// LabelManagedBy marks MPIJobs which are created by the tracker. The
+// value is always ManagedByValue.
+const LabelManagedBy = "mpioperatortracker.drmaa2.io/managed-by"
+
+// ManagedByValue is the value of the LabelManagedBy label.
+const ManagedByValue = "mpioperatortracker"
+
+// LabelJobOwner contains the user who submitted the job converted into
+// a valid label value (like "DOMAIN_user" for "DOMAIN\user").
+const LabelJobOwner = "mpioperatortracker.drmaa2.io/owner"
+
+// AnnotationJobOwner contains the unmodified name of the user who
+// submitted the job. It is reported as the job owner in the job info.
+const AnnotationJobOwner = "mpioperatortracker.drmaa2.io/owner"
+
+// LabelJobSession contains the name of the job session the job
+// belongs to.
+const LabelJobSession = "mpioperatortracker.drmaa2.io/job-session"
+
 func NewMPIJob(spec kubeflow.MPIJobSpec) (job kubeflow.MPIJob) {
 	job.Namespace = "default"
 	job.GenerateName = "drmaa2-mpioperator-job-"
+	job.Labels = map[string]string{
+		LabelManagedBy: ManagedByValue,
+	}
 	job.Spec = spec
 	return
 }
 
+// SetJobOwner sets the owner label and annotation of the job. Characters
+// which are not allowed in label values are replaced in the label.
+func SetJobOwner(job *kubeflow.MPIJob, owner string) {
+	if job.Labels == nil {
+		job.Labels = make(map[string]string)
+	}
+	job.Labels[LabelJobOwner] = toLabelValue(owner)
+	if job.Annotations == nil {
+		job.Annotations = make(map[string]string)
+	}
+	job.Annotations[AnnotationJobOwner] = owner
+}
+
+// JobOwner returns the user who submitted the job. Jobs without the
+// owner annotation report the owner label.
+func JobOwner(job *kubeflow.MPIJob) string {
+	if owner, exists := job.Annotations[AnnotationJobOwner]; exists {
+		return owner
+	}
+	return job.Labels[LabelJobOwner]
+}
+
+// CurrentJobOwner returns the name of the user running the process.
+func CurrentJobOwner() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
+
+// toLabelValue converts a string into a valid label value.
+func toLabelValue(value string) string {
+	valid := []rune{}
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '.':
+			valid = append(valid, r)
+		default:
+			valid = append(valid, '_')
+		}
+	}
+	if len(valid) > validation.LabelValueMaxLength {
+		valid = valid[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(string(valid), "-_.")
+}
+
 func CreateJob(ctx context.Context, mpiClient clientset.Interface, mpiJob *kubeflow.MPIJob, waitForJob bool) (*kubeflow.MPIJob, error) {
 	mpiJob, err := mpiClient.KubeflowV2beta1().MPIJobs(mpiJob.Namespace).Create(ctx, mpiJob, metav1.CreateOptions{})
 	if err != nil {
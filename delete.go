@@ -2,11 +2,105 @@ package mpioperatortracker
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
 	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 )
 
+// DeleteJobOptions define how an MPIJob is removed from the cluster.
+type DeleteJobOptions struct {
+	// PropagationPolicy is the Kubernetes deletion propagation policy
+	// (metav1.DeletePropagationForeground, metav1.DeletePropagationBackground,
+	// or metav1.DeletePropagationOrphan). Empty uses the cluster default.
+	PropagationPolicy metav1.DeletionPropagation
+	// WaitForPods blocks until all launcher and worker pods of the job
+	// are gone.
+	WaitForPods bool
+	// PodDeletionTimeout is the max. time to wait for the pods when
+	// WaitForPods is set. 0 means one minute.
+	PodDeletionTimeout time.Duration
+}
+
+// DefaultDeleteJobOptions deletes the MPIJob in the background without
+// waiting for its pods.
+var DefaultDeleteJobOptions = DeleteJobOptions{
+	PropagationPolicy: metav1.DeletePropagationBackground,
+}
+
+// JobNotInEndStateError is returned when a job is requested to be deleted
+// which is not yet in an end state (done or failed).
+type JobNotInEndStateError struct {
+	JobID    string
+	State    drmaa2interface.JobState
+	SubState string
+}
+
+func (e *JobNotInEndStateError) Error() string {
+	return fmt.Sprintf("job %s is not in an end state (state: %s, substate: %s)",
+		e.JobID, e.State.String(), e.SubState)
+}
+
+// IsEndState returns true if the job state is Done or Failed.
+func IsEndState(state drmaa2interface.JobState) bool {
+	return state == drmaa2interface.Done || state == drmaa2interface.Failed
+}
+
 func DeleteJob(ctx context.Context, mpiClient clientset.Interface, namespace, jobName string) error {
-	return mpiClient.KubeflowV2beta1().MPIJobs(namespace).Delete(ctx, jobName, metav1.DeleteOptions{})
+	return DeleteJobWithOptions(ctx, mpiClient, nil, namespace, jobName, DefaultDeleteJobOptions)
+}
+
+// DeleteJobWithOptions removes the MPIJob with the given propagation policy.
+// When opts.WaitForPods is set it waits until the launcher and worker pods
+// are removed, which requires the kubeClient.
+func DeleteJobWithOptions(ctx context.Context, mpiClient clientset.Interface, kubeClient kubernetes.Interface, namespace, jobName string, opts DeleteJobOptions) error {
+	deleteOptions := metav1.DeleteOptions{}
+	if opts.PropagationPolicy != "" {
+		policy := opts.PropagationPolicy
+		deleteOptions.PropagationPolicy = &policy
+	}
+	err := mpiClient.KubeflowV2beta1().MPIJobs(namespace).Delete(ctx, jobName, deleteOptions)
+	if err != nil {
+		return err
+	}
+	if !opts.WaitForPods {
+		return nil
+	}
+	if kubeClient == nil {
+		return fmt.Errorf("kubernetes client is required for waiting on pod deletion")
+	}
+	timeout := opts.PodDeletionTimeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	return WaitForJobPodsDeleted(ctx, kubeClient, namespace, jobName, timeout)
+}
+
+// podDeletionPollInterval is the interval for checking if the pods of a
+// deleted job are gone.
+var podDeletionPollInterval = time.Second
+
+// WaitForJobPodsDeleted blocks until no pod belonging to the MPIJob exists
+// anymore or the timeout is reached.
+func WaitForJobPodsDeleted(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string, timeout time.Duration) error {
+	selector := labels.SelectorFromSet(labels.Set{common.JobNameLabel: jobName}).String()
+	err := wait.PollImmediate(podDeletionPollInterval, timeout, func() (bool, error) {
+		pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("pods of job %s are not deleted: %v", jobName, err)
+	}
+	return nil
 }
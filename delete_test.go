@@ -0,0 +1,113 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeMPIJob(name string, conditions ...common.JobConditionType) *kubeflow.MPIJob {
+	job := &kubeflow.MPIJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+	}
+	for _, c := range conditions {
+		job.Status.Conditions = append(job.Status.Conditions,
+			common.JobCondition{Type: c})
+	}
+	return job
+}
+
+var _ = Describe("DeleteJob", func() {
+
+	var tracker *MPIOperatorTracker
+
+	BeforeEach(func() {
+		tracker = &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(
+				newFakeMPIJob("running", common.JobCreated, common.JobRunning),
+				newFakeMPIJob("succeeded", common.JobCreated, common.JobRunning, common.JobSucceeded),
+			),
+			kubeClient: k8sfake.NewSimpleClientset(),
+		}
+	})
+
+	It("should refuse to delete a job which is not in an end state", func() {
+		err := tracker.DeleteJob("running")
+		Expect(err).NotTo(BeNil())
+		var notInEndState *JobNotInEndStateError
+		Expect(errors.As(err, &notInEndState)).To(BeTrue())
+		Expect(notInEndState.State).To(Equal(drmaa2interface.Running))
+		state, _, err := tracker.JobState("running")
+		Expect(err).To(BeNil())
+		Expect(state).To(Equal(drmaa2interface.Running))
+	})
+
+	It("should delete a finished job and wait for its pods", func() {
+		podDeletionPollInterval = 10 * time.Millisecond
+		defer func() { podDeletionPollInterval = time.Second }()
+
+		launcher := newFakeLauncherPod("succeeded-launcher", "succeeded", time.Now())
+		worker := newFakeLauncherPod("succeeded-worker-0", "succeeded", time.Now())
+		worker.Labels[common.JobRoleLabel] = "worker"
+		kubeClient := k8sfake.NewSimpleClientset(launcher, worker)
+		tracker.kubeClient = kubeClient
+
+		// the pods are removed by the garbage collector some time after
+		// the MPIJob is deleted
+		const gcDelay = 200 * time.Millisecond
+		var gcDone sync.WaitGroup
+		tracker.clientset.(*fake.Clientset).PrependReactor("delete", "mpijobs",
+			func(action k8stesting.Action) (bool, runtime.Object, error) {
+				gcDone.Add(1)
+				go func() {
+					defer gcDone.Done()
+					time.Sleep(gcDelay)
+					for _, pod := range []string{launcher.Name, worker.Name} {
+						kubeClient.CoreV1().Pods("default").Delete(context.Background(),
+							pod, metav1.DeleteOptions{})
+					}
+				}()
+				return false, nil, nil
+			})
+
+		start := time.Now()
+		err := tracker.DeleteJobWithOptions("succeeded", DeleteJobOptions{
+			PropagationPolicy: metav1.DeletePropagationForeground,
+			WaitForPods:       true,
+		})
+		Expect(err).To(BeNil())
+		Expect(time.Since(start)).To(BeNumerically(">=", gcDelay))
+		pods, err := kubeClient.CoreV1().Pods("default").List(context.Background(), metav1.ListOptions{})
+		Expect(err).To(BeNil())
+		Expect(pods.Items).To(BeEmpty())
+		_, _, err = tracker.JobState("succeeded")
+		Expect(err).NotTo(BeNil())
+		gcDone.Wait()
+	})
+
+	It("should fail when the pods of a deleted job are not removed in time", func() {
+		tracker.kubeClient = k8sfake.NewSimpleClientset(
+			newFakeLauncherPod("succeeded-launcher", "succeeded", time.Now()))
+		err := tracker.DeleteJobWithOptions("succeeded", DeleteJobOptions{
+			WaitForPods:        true,
+			PodDeletionTimeout: 50 * time.Millisecond,
+		})
+		Expect(err).NotTo(BeNil())
+	})
+
+})
@@ -24,6 +24,16 @@ func ListJobs(ctx context.Context, mpiClient clientset.Interface, namespace stri
 	return mpiJobList.Items, nil
 }
 
+// ListJobsWithOptions returns one page of MPIJobs matching the list
+// options. The continue token for the next page is part of the returned
+// list.
+func ListJobsWithOptions(ctx context.Context, mpiClient clientset.Interface, namespace string, opts metav1.ListOptions) (*kubeflow.MPIJobList, error) {
+	if mpiClient == nil {
+		return nil, fmt.Errorf("MPI client is nil")
+	}
+	return mpiClient.KubeflowV2beta1().MPIJobs(namespace).List(ctx, opts)
+}
+
 func DescribeJob(ctx context.Context, mpiClient clientset.Interface, namespace, jobName string) (*kubeflow.MPIJob, error) {
 	return mpiClient.KubeflowV2beta1().MPIJobs(namespace).Get(ctx, jobName, metav1.GetOptions{})
 }
@@ -41,10 +51,48 @@ func GetJobState(ctx context.Context, mpiClient clientset.Interface, namespace,
 	if err != nil {
 		return drmaa2interface.Undetermined, "unknown job", err
 	}
+	return JobStateFromMPIJob(job)
+}
+
+// JobStateFromMPIJob derives the DRMAA2 job state from the last condition
+// of the job. Jobs terminated by the tracker are reported as failed with
+// the substate "terminated".
+func JobStateFromMPIJob(job *kubeflow.MPIJob) (drmaa2interface.JobState, string, error) {
+	if IsTerminated(job) {
+		return drmaa2interface.Failed, "terminated", nil
+	}
 	if len(job.Status.Conditions) == 0 {
 		return drmaa2interface.Queued, "no condition", nil
 	}
-	return JobStateFromCondition(job.Status.Conditions[len(job.Status.Conditions)-1])
+	state, subState, err := JobStateFromCondition(job.Status.Conditions[len(job.Status.Conditions)-1])
+	if err != nil {
+		return state, subState, err
+	}
+	if retries := Retries(job); retries > 0 && !IsEndState(state) {
+		if state == drmaa2interface.Running && !launcherActive(job) {
+			// the failed launcher is not yet replaced
+			state, subState = drmaa2interface.Requeued, "restarting"
+		}
+		subState = fmt.Sprintf("%s (retry %d", subState, retries)
+		if job.Spec.RunPolicy.BackoffLimit != nil {
+			subState += fmt.Sprintf(" of %d", *job.Spec.RunPolicy.BackoffLimit)
+		}
+		subState += ")"
+	}
+	return state, subState, nil
+}
+
+// Retries returns how often the launcher of the MPIJob failed and
+// was restarted.
+func Retries(job *kubeflow.MPIJob) int32 {
+	if job.Status.ReplicaStatuses == nil {
+		return 0
+	}
+	launcher := job.Status.ReplicaStatuses[common.ReplicaType(kubeflow.MPIReplicaTypeLauncher)]
+	if launcher == nil {
+		return 0
+	}
+	return launcher.Failed
 }
 
 func JobStateFromCondition(lastCondition common.JobCondition) (drmaa2interface.JobState, string, error) {
@@ -62,3 +110,8 @@ func JobStateFromCondition(lastCondition common.JobCondition) (drmaa2interface.J
 	}
 	return drmaa2interface.Undetermined, fmt.Sprintf("unknown condition type %v", lastCondition.Type), nil
 }
+
+func launcherActive(job *kubeflow.MPIJob) bool {
+	launcher := job.Status.ReplicaStatuses[common.ReplicaType(kubeflow.MPIReplicaTypeLauncher)]
+	return launcher != nil && launcher.Active > 0
+}
@@ -0,0 +1,74 @@
+package mpioperatortracker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/dgruber/drmaa2interface"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// JobNotFoundError is returned for jobs which don't exist.
+type JobNotFoundError struct {
+	JobID string
+}
+
+func (e *JobNotFoundError) Error() string {
+	return fmt.Sprintf("job %s not found", e.JobID)
+}
+
+// ErrorID returns the DRMAA2 error ID of an error returned by the tracker,
+// like InvalidArgument for unknown jobs (JobNotFoundError), InvalidState for jobs which are
+// not in the required state, or DeniedByDrms for requests which are not
+// permitted by the Kubernetes API server. Errors of the tracker are
+// drmaa2interface.Error values or one of the error types of this package;
+// Kubernetes API errors are classified by their status. Other errors are
+// Internal.
+func ErrorID(err error) drmaa2interface.ErrorID {
+	if err == nil {
+		return drmaa2interface.Success
+	}
+	var drmaa2Error drmaa2interface.Error
+	var notFound *JobNotFoundError
+	var notInEndState *JobNotInEndStateError
+	var jobsExist *MPIJobsExistError
+	var operatorNotFound *OperatorNotFoundError
+	var netError net.Error
+	switch {
+	case errors.As(err, &drmaa2Error):
+		return drmaa2Error.ID
+	case errors.As(err, &notFound):
+		return drmaa2interface.InvalidArgument
+	case errors.As(err, &notInEndState), errors.As(err, &jobsExist):
+		return drmaa2interface.InvalidState
+	case errors.As(err, &operatorNotFound):
+		return drmaa2interface.DrmCommunication
+	case apierrors.IsNotFound(err), apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return drmaa2interface.InvalidArgument
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return drmaa2interface.DeniedByDrms
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return drmaa2interface.Timeout
+	case apierrors.IsConflict(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err):
+		return drmaa2interface.TryLater
+	case errors.As(err, &netError):
+		return drmaa2interface.DrmCommunication
+	}
+	return drmaa2interface.Internal
+}
+
+// newError returns a drmaa2interface.Error with the given ID.
+func newError(id drmaa2interface.ErrorID, format string, args ...interface{}) error {
+	return drmaa2interface.Error{ID: id, Message: fmt.Sprintf(format, args...)}
+}
+
+// wrapError returns a drmaa2interface.Error whose ID is the ErrorID of err
+// and whose message is the description followed by the message of err.
+func wrapError(err error, format string, args ...interface{}) error {
+	return drmaa2interface.Error{
+		ID:      ErrorID(err),
+		Message: fmt.Sprintf(format, args...) + ": " + err.Error(),
+	}
+}
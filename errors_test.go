@@ -0,0 +1,74 @@
+package mpioperatortracker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var _ = Describe("Errors", func() {
+
+	var tracker *MPIOperatorTracker
+
+	BeforeEach(func() {
+		tracker = &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(
+				newFakeMPIJob("running", common.JobCreated, common.JobRunning),
+				newFakeMPIJob("succeeded", common.JobCreated, common.JobRunning, common.JobSucceeded),
+			),
+		}
+	})
+
+	It("should return errors with DRMAA2 error IDs", func() {
+		_, _, err := tracker.JobState("unknown")
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidArgument))
+		Expect(err).To(BeAssignableToTypeOf(&JobNotFoundError{}))
+
+		_, err = tracker.JobInfo("unknown")
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidArgument))
+
+		err = tracker.JobControl("running", "suspend")
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.UnsupportedOperation))
+
+		err = tracker.JobControl("running", "pause")
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidArgument))
+
+		err = tracker.JobControl("succeeded", "terminate")
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidState))
+
+		err = tracker.DeleteJob("running")
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidState))
+
+		_, err = tracker.AddJob(SetLauncherRestartPolicyExtension(drmaa2interface.JobTemplate{
+			JobCategory: "mpioperator/mpi-pi:openmpi",
+			MinSlots:    2,
+		}, common.RestartPolicyAlways))
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidArgument))
+
+		err = tracker.Wait("running", 200*time.Millisecond, drmaa2interface.Done)
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.Timeout))
+
+		err = tracker.Wait("unknown", time.Second, drmaa2interface.Done)
+		Expect(ErrorID(err)).To(Equal(drmaa2interface.InvalidArgument))
+	})
+
+	It("should classify Kubernetes API errors", func() {
+		resource := schema.GroupResource{Group: "kubeflow.org", Resource: "mpijobs"}
+		Expect(ErrorID(nil)).To(Equal(drmaa2interface.Success))
+		Expect(ErrorID(apierrors.NewForbidden(resource, "job", errors.New("denied")))).
+			To(Equal(drmaa2interface.DeniedByDrms))
+		Expect(ErrorID(apierrors.NewTimeoutError("slow", 1))).To(Equal(drmaa2interface.Timeout))
+		Expect(ErrorID(apierrors.NewConflict(resource, "job", errors.New("changed")))).
+			To(Equal(drmaa2interface.TryLater))
+		Expect(ErrorID(&OperatorNotFoundError{})).To(Equal(drmaa2interface.DrmCommunication))
+		Expect(ErrorID(errors.New("timeout"))).To(Equal(drmaa2interface.Internal))
+	})
+
+})
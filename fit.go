@@ -0,0 +1,97 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgruber/drmaa2interface"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NodeFitCheck defines what happens when the resource requests of a
+// worker don't fit on any node of the cluster.
+type NodeFitCheck string
+
+const (
+	// NodeFitCheckNone does not check the worker requests (default).
+	NodeFitCheckNone NodeFitCheck = ""
+	// NodeFitCheckWarn logs a warning and submits the job.
+	NodeFitCheckWarn NodeFitCheck = "warn"
+	// NodeFitCheckError rejects the job.
+	NodeFitCheckError NodeFitCheck = "error"
+)
+
+// WorkerFitsAnyNode checks if the resource requests of a worker of the
+// MPIJob spec fit into the allocatable resources of at least one
+// schedulable node. Limits are used for resources without request.
+func WorkerFitsAnyNode(ctx context.Context, kubeClient kubernetes.Interface, spec kubeflow.MPIJobSpec) error {
+	worker := spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker]
+	if worker == nil || len(worker.Template.Spec.Containers) == 0 {
+		return nil
+	}
+	requests := containerRequests(worker.Template.Spec.Containers[0])
+	if len(requests) == 0 {
+		return nil
+	}
+	nodeList, err := kubeClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes node list: %v", err)
+	}
+	for i := range nodeList.Items {
+		if nodeList.Items[i].Spec.Unschedulable {
+			continue
+		}
+		if fitsInto(requests, nodeList.Items[i].Status.Allocatable) {
+			return nil
+		}
+	}
+	return newError(drmaa2interface.OutOfResource, "worker requests %v do not fit on any of the %d nodes",
+		requests, len(nodeList.Items))
+}
+
+// containerRequests returns the requests of the container where limits
+// are used as requests if not set, like Kubernetes does.
+func containerRequests(container v1.Container) v1.ResourceList {
+	requests := make(v1.ResourceList)
+	for name, quantity := range container.Resources.Limits {
+		requests[name] = quantity
+	}
+	for name, quantity := range container.Resources.Requests {
+		requests[name] = quantity
+	}
+	return requests
+}
+
+func fitsInto(requests, allocatable v1.ResourceList) bool {
+	for name, request := range requests {
+		available, exists := allocatable[name]
+		if !exists || available.Cmp(request) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNodeFit applies the NodeFitCheck of the tracker to the job spec.
+func (t *MPIOperatorTracker) checkNodeFit(spec kubeflow.MPIJobSpec) error {
+	if t.nodeFitCheck == NodeFitCheckNone || t.kubeClient == nil {
+		return nil
+	}
+	err := WorkerFitsAnyNode(context.Background(), t.kubeClient, spec)
+	if err == nil {
+		return nil
+	}
+	if ErrorID(err) != drmaa2interface.OutOfResource {
+		// like when listing nodes is not allowed; no fit decision was made
+		t.jobLogger("", "submit").Error(err, "skipping node fit check")
+		return nil
+	}
+	if t.nodeFitCheck == NodeFitCheckError {
+		return err
+	}
+	t.jobLogger("", "submit").Info("job can not be scheduled", "reason", err.Error())
+	return nil
+}
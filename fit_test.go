@@ -0,0 +1,79 @@
+package mpioperatortracker
+
+import (
+	"errors"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Node fit check", func() {
+
+	var tracker *MPIOperatorTracker
+	var jt drmaa2interface.JobTemplate
+
+	BeforeEach(func() {
+		tracker = &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(),
+			kubeClient: k8sfake.NewSimpleClientset(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("4"),
+						corev1.ResourceMemory: resource.MustParse("16Gi"),
+					},
+				},
+			}),
+			nodeFitCheck: NodeFitCheckError,
+		}
+		jt = drmaa2interface.JobTemplate{
+			JobCategory: "mpioperator/mpi-pi:intel",
+			MinSlots:    2,
+		}
+	})
+
+	It("should accept jobs with workers fitting on a node", func() {
+		jt = SetWorkerResourceRequestsExtension(jt, corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("4"),
+		})
+		_, err := tracker.AddJob(jt)
+		Expect(err).To(BeNil())
+	})
+
+	It("should reject jobs with workers which never fit on a node", func() {
+		jt = SetWorkerResourceLimitExtension(jt, corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("32Gi"),
+		})
+		_, err := tracker.AddJob(jt)
+		Expect(err).NotTo(BeNil())
+		_, err = tracker.AddArrayJob(jt, 1, 2, 1, 0)
+		Expect(err).NotTo(BeNil())
+
+		tracker.nodeFitCheck = NodeFitCheckWarn
+		_, err = tracker.AddJob(jt)
+		Expect(err).To(BeNil())
+	})
+
+	It("should skip the check when the nodes can not be listed", func() {
+		kubeClient := k8sfake.NewSimpleClientset()
+		kubeClient.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(corev1.Resource("nodes"), "", errors.New("no RBAC"))
+		})
+		tracker.kubeClient = kubeClient
+		jt = SetWorkerResourceLimitExtension(jt, corev1.ResourceList{
+			corev1.ResourceMemory: resource.MustParse("32Gi"),
+		})
+		_, err := tracker.AddJob(jt)
+		Expect(err).To(BeNil())
+	})
+
+})
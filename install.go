@@ -1,37 +1,363 @@
 package mpioperatortracker
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/dgruber/drmaa2interface"
-	"github.com/dgruber/wfl"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 )
 
 //go:embed mpi-operator.yaml
 var mpiOperatorYaml string
 
+// FieldManager is the field manager used for server-side apply.
+const FieldManager = "mpioperatortracker"
+
+// ApplyResult is the result of applying one object of a manifest.
+type ApplyResult struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Action is what was done with the object (ActionApplied,
+	// ActionDeleted, or ActionNotFound).
+	Action string
+	// Err is set when the action failed.
+	Err error
+}
+
+// Actions of an ApplyResult.
+const (
+	ActionApplied  = "applied"
+	ActionDeleted  = "deleted"
+	ActionNotFound = "not found"
+)
+
+func (r ApplyResult) String() string {
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + r.Name
+	}
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s: %v", r.Kind, name, r.Err)
+	}
+	return fmt.Sprintf("%s %s: %s", r.Kind, name, r.Action)
+}
+
+// InstallOptions define how the MPI operator is installed or upgraded.
+type InstallOptions struct {
+	// ReadyTimeout is the max. time to wait until the MPI operator is
+	// ready. 0 means DefaultReadyTimeout.
+	ReadyTimeout time.Duration
+	// SkipWait returns directly after the manifest is applied.
+	SkipWait bool
+	// Manifest replaces the embedded MPI operator manifest.
+	Manifest []byte
+	// ManifestPath is the path to a manifest file which replaces the
+	// embedded MPI operator manifest. Manifest takes precedence.
+	ManifestPath string
+	// Image overrides the image of the operator container, like for
+	// using a mirrored registry.
+	Image string
+	// Namespace overrides the namespace the operator runs in. Default
+	// is OperatorNamespace.
+	Namespace string
+	// GangScheduling sets the gang scheduler the operator uses for the
+	// launcher and workers (like "volcano").
+	GangScheduling string
+	// ExtraArgs are appended to the arguments of the operator container.
+	ExtraArgs []string
+}
+
+// namespace returns the namespace the operator is installed in.
+func (opts InstallOptions) namespace() string {
+	if opts.Namespace == "" {
+		return OperatorNamespace
+	}
+	return opts.Namespace
+}
+
+// InstallMPIOperator installs the embedded MPI operator manifest in the
+// cluster defined by the kubeconfig and waits until the operator is ready.
 func InstallMPIOperator(kubeconfigPath string) error {
-	flow := wfl.NewWorkflow(wfl.NewProcessContextByCfg(
-		wfl.ProcessConfig{
-			DefaultTemplate: drmaa2interface.JobTemplate{
-				OutputPath: "/dev/stdout",
-				ErrorPath:  "/dev/stderr",
-				JobEnvironment: map[string]string{
-					"KUBECONFIG": kubeconfigPath,
-				},
-			},
-		},
-	))
-	file, _ := ioutil.TempFile("", "mpiOperator.yaml")
-	file.WriteString(mpiOperatorYaml)
-	file.Close()
-	defer os.Remove(file.Name())
-	job := flow.Run("/bin/bash", "-c", `cat `+file.Name()+` | kubectl apply -f -`).Wait()
-	if job.Success() {
+	return InstallMPIOperatorWithOptions(kubeconfigPath, InstallOptions{})
+}
+
+// InstallMPIOperatorWithOptions installs the MPI operator in the cluster
+// defined by the kubeconfig with the given options.
+func InstallMPIOperatorWithOptions(kubeconfigPath string, opts InstallOptions) error {
+	restConfig, err := NewRestConfig(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to create REST config: %v", err)
+	}
+	_, err = InstallMPIOperatorWithConfig(context.Background(), restConfig, opts)
+	return err
+}
+
+// InstallMPIOperatorWithConfig applies all objects of the MPI operator
+// manifest (see ManifestObjects) with server-side apply. It returns the
+// result of each object and an error if at least one object could not be
+// applied. Unless opts.SkipWait is set it waits until the MPIJob CRD is
+// established and the operator deployment is available.
+func InstallMPIOperatorWithConfig(ctx context.Context, restConfig *rest.Config, opts InstallOptions) ([]ApplyResult, error) {
+	objects, err := ManifestObjects(opts)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, mapper, err := newDynamicClientAndMapper(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	results, err := ApplyObjects(ctx, dynamicClient, mapper, objects)
+	if err != nil {
+		return results, fmt.Errorf("failed to install MPI Operator: %v", err)
+	}
+	return results, waitForInstallation(ctx, restConfig, dynamicClient, opts)
+}
+
+// ManifestObjects returns the objects of the MPI operator manifest
+// defined by the options (the embedded one by default) with the image,
+// namespace, and operator arguments overridden as configured.
+func ManifestObjects(opts InstallOptions) ([]*unstructured.Unstructured, error) {
+	manifest := opts.Manifest
+	if manifest == nil && opts.ManifestPath != "" {
+		var err error
+		manifest, err = os.ReadFile(opts.ManifestPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %v", err)
+		}
+	}
+	if manifest == nil {
+		manifest = []byte(mpiOperatorYaml)
+	}
+	objects, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Namespace != "" && opts.Namespace != OperatorNamespace {
+		if err := setOperatorNamespace(objects, opts.Namespace); err != nil {
+			return nil, err
+		}
+	}
+	args := opts.ExtraArgs
+	if opts.GangScheduling != "" {
+		args = append([]string{"--gang-scheduling=" + opts.GangScheduling}, args...)
+	}
+	if opts.Image == "" && len(args) == 0 {
+		return objects, nil
+	}
+	deployment, containers, index := operatorContainer(objects)
+	if deployment == nil {
+		return nil, fmt.Errorf("no MPI operator deployment found in manifest")
+	}
+	container := containers[index].(map[string]interface{})
+	if opts.Image != "" {
+		container["image"] = opts.Image
+	}
+	if len(args) > 0 {
+		existing, _, _ := unstructured.NestedStringSlice(container, "args")
+		if err := unstructured.SetNestedStringSlice(container, append(existing, args...), "args"); err != nil {
+			return nil, err
+		}
+	}
+	err = unstructured.SetNestedSlice(deployment.Object, containers,
+		"spec", "template", "spec", "containers")
+	return objects, err
+}
+
+// setOperatorNamespace moves the namespaced objects of the operator
+// namespace, the namespace itself, and the references to it into the
+// given namespace.
+func setOperatorNamespace(objects []*unstructured.Unstructured, namespace string) error {
+	for _, object := range objects {
+		if object.GetNamespace() == OperatorNamespace {
+			object.SetNamespace(namespace)
+		}
+		switch object.GetKind() {
+		case "Namespace":
+			if object.GetName() == OperatorNamespace {
+				object.SetName(namespace)
+			}
+		case "ClusterRoleBinding", "RoleBinding":
+			subjects, _, _ := unstructured.NestedSlice(object.Object, "subjects")
+			for _, s := range subjects {
+				if subject, ok := s.(map[string]interface{}); ok && subject["namespace"] == OperatorNamespace {
+					subject["namespace"] = namespace
+				}
+			}
+			if err := unstructured.SetNestedSlice(object.Object, subjects, "subjects"); err != nil {
+				return err
+			}
+		}
+	}
+	deployment, containers, index := operatorContainer(objects)
+	if deployment == nil {
 		return nil
 	}
-	return fmt.Errorf("failed to install MPI Operator")
+	container := containers[index].(map[string]interface{})
+	args, _, _ := unstructured.NestedStringSlice(container, "args")
+	for i, arg := range args {
+		if arg == "--lock-namespace="+OperatorNamespace {
+			args[i] = "--lock-namespace=" + namespace
+		}
+		if arg == "--lock-namespace" && i+1 < len(args) && args[i+1] == OperatorNamespace {
+			args[i+1] = namespace
+		}
+	}
+	if err := unstructured.SetNestedStringSlice(container, args, "args"); err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(deployment.Object, containers,
+		"spec", "template", "spec", "containers")
+}
+
+// operatorContainer returns the operator deployment of the manifest, its
+// containers, and the index of the operator container, which is the
+// container named like the operator deployment or the first one.
+func operatorContainer(objects []*unstructured.Unstructured) (*unstructured.Unstructured, []interface{}, int) {
+	var first *unstructured.Unstructured
+	var firstContainers []interface{}
+	for _, object := range objects {
+		if object.GetKind() != "Deployment" {
+			continue
+		}
+		containers, _, _ := unstructured.NestedSlice(object.Object,
+			"spec", "template", "spec", "containers")
+		for i, c := range containers {
+			if container, ok := c.(map[string]interface{}); ok && container["name"] == OperatorDeploymentName {
+				return object, containers, i
+			}
+		}
+		if first == nil && len(containers) > 0 {
+			first, firstContainers = object, containers
+		}
+	}
+	return first, firstContainers, 0
+}
+
+func waitForInstallation(ctx context.Context, restConfig *rest.Config, dynamicClient dynamic.Interface, opts InstallOptions) error {
+	if opts.SkipWait {
+		return nil
+	}
+	kubeClient, err := GetKubernetesClient(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %v", err)
+	}
+	return WaitForMPIOperator(ctx, dynamicClient, kubeClient, opts.namespace(), opts.ReadyTimeout)
+}
+
+func newDynamicClientAndMapper(restConfig *rest.Config) (dynamic.Interface, meta.RESTMapper, error) {
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create discovery client: %v", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	return dynamicClient, mapper, nil
+}
+
+// DecodeManifest decodes a multi-document YAML (or JSON) manifest into
+// its objects. Empty documents are skipped.
+func DecodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		object := &unstructured.Unstructured{}
+		err := decoder.Decode(&object.Object)
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %v", err)
+		}
+		if len(object.Object) == 0 {
+			continue
+		}
+		objects = append(objects, object)
+	}
+}
+
+// ApplyManifest applies all objects of the manifest in their order with
+// server-side apply. All objects are tried to be applied even when some
+// fail. The returned error lists the objects which failed.
+func ApplyManifest(ctx context.Context, dynamicClient dynamic.Interface, mapper meta.RESTMapper, manifest []byte) ([]ApplyResult, error) {
+	objects, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyObjects(ctx, dynamicClient, mapper, objects)
+}
+
+// ApplyObjects applies the objects like ApplyManifest.
+func ApplyObjects(ctx context.Context, dynamicClient dynamic.Interface, mapper meta.RESTMapper, objects []*unstructured.Unstructured) ([]ApplyResult, error) {
+	results := make([]ApplyResult, 0, len(objects))
+	var failed []string
+	for _, object := range objects {
+		result := ApplyResult{
+			Kind:      object.GetKind(),
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+			Action:    ActionApplied,
+			Err:       applyObject(ctx, dynamicClient, mapper, object),
+		}
+		if result.Err != nil {
+			failed = append(failed, result.String())
+		}
+		results = append(results, result)
+	}
+	if len(failed) > 0 {
+		return results, errors.New(strings.Join(failed, "; "))
+	}
+	return results, nil
+}
+
+func applyObject(ctx context.Context, dynamicClient dynamic.Interface, mapper meta.RESTMapper, object *unstructured.Unstructured) error {
+	resource, err := resourceInterface(dynamicClient, mapper, object)
+	if err != nil {
+		return err
+	}
+	data, err := object.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	force := true
+	_, err = resource.Patch(ctx, object.GetName(), types.ApplyPatchType, data,
+		metav1.PatchOptions{FieldManager: FieldManager, Force: &force})
+	return err
+}
+
+// resourceInterface returns the dynamic client interface for the
+// resource of the object.
+func resourceInterface(dynamicClient dynamic.Interface, mapper meta.RESTMapper, object *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s to a resource: %v", gvk.String(), err)
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := object.GetNamespace()
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		return dynamicClient.Resource(mapping.Resource).Namespace(namespace), nil
+	}
+	return dynamicClient.Resource(mapping.Resource), nil
 }
@@ -0,0 +1,123 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newManifestRESTMapper returns a RESTMapper knowing all kinds of the
+// embedded MPI operator manifest.
+func newManifestRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, kind := range []struct {
+		gvk   schema.GroupVersionKind
+		scope meta.RESTScope
+	}{
+		{schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot},
+		{schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot},
+		{schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}, meta.RESTScopeNamespace},
+		{schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot},
+		{schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRoleBinding"}, meta.RESTScopeRoot},
+		{schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace},
+	} {
+		mapper.Add(kind.gvk, kind.scope)
+	}
+	return mapper
+}
+
+var _ = Describe("Install", func() {
+
+	It("should decode the embedded manifest", func() {
+		objects, err := DecodeManifest([]byte(mpiOperatorYaml))
+		Expect(err).To(BeNil())
+		Expect(objects).To(HaveLen(9))
+		Expect(objects[0].GetKind()).To(Equal("Namespace"))
+		Expect(objects[8].GetKind()).To(Equal("Deployment"))
+		Expect(objects[8].GetNamespace()).To(Equal("mpi-operator"))
+	})
+
+	It("should apply all objects with server-side apply", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+		var applied []string
+		dynamicClient.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			patch := action.(k8stesting.PatchAction)
+			Expect(patch.GetPatchType()).To(Equal(types.ApplyPatchType))
+			if patch.GetResource().Resource == "clusterrolebindings" {
+				return true, nil, errors.New("forbidden")
+			}
+			applied = append(applied, patch.GetResource().Resource+"/"+patch.GetName())
+			return true, nil, nil
+		})
+		results, err := ApplyManifest(context.Background(), dynamicClient,
+			newManifestRESTMapper(), []byte(mpiOperatorYaml))
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("ClusterRoleBinding"))
+		Expect(results).To(HaveLen(9))
+		Expect(results[7].Err).NotTo(BeNil())
+		Expect(applied).To(HaveLen(8))
+		Expect(applied).To(ContainElement("deployments/mpi-operator"))
+	})
+
+	It("should override image, namespace, and operator arguments", func() {
+		objects, err := ManifestObjects(InstallOptions{
+			Image:          "registry.local:5000/mpioperator/mpi-operator:0.3.0",
+			Namespace:      "hpc-system",
+			GangScheduling: "volcano",
+			ExtraArgs:      []string{"--monitoring-port=8081"},
+		})
+		Expect(err).To(BeNil())
+		Expect(objects).To(HaveLen(9))
+		Expect(objects[0].GetName()).To(Equal("hpc-system"))
+		for _, object := range objects {
+			Expect(object.GetNamespace()).NotTo(Equal(OperatorNamespace))
+		}
+		subjects, _, _ := unstructured.NestedSlice(objects[7].Object, "subjects")
+		Expect(subjects[0].(map[string]interface{})["namespace"]).To(Equal("hpc-system"))
+
+		deployment := objects[8]
+		Expect(deployment.GetNamespace()).To(Equal("hpc-system"))
+		containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+		container := containers[0].(map[string]interface{})
+		Expect(container["image"]).To(Equal("registry.local:5000/mpioperator/mpi-operator:0.3.0"))
+		args, _, _ := unstructured.NestedStringSlice(container, "args")
+		Expect(args).To(Equal([]string{"-alsologtostderr", "--lock-namespace", "hpc-system",
+			"--gang-scheduling=volcano", "--monitoring-port=8081"}))
+	})
+
+	It("should read the manifest from a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "operator.yaml")
+		Expect(os.WriteFile(path, []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: operator
+  namespace: mirror
+spec:
+  template:
+    spec:
+      containers:
+      - name: operator
+        image: mpioperator/mpi-operator:0.2.3
+`), 0600)).To(Succeed())
+		objects, err := ManifestObjects(InstallOptions{ManifestPath: path, Image: "mirror/mpi-operator:0.2.3"})
+		Expect(err).To(BeNil())
+		Expect(objects).To(HaveLen(1))
+		containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+		Expect(containers[0].(map[string]interface{})["image"]).To(Equal("mirror/mpi-operator:0.2.3"))
+
+		_, err = ManifestObjects(InstallOptions{Manifest: []byte("kind: Namespace\n"), Image: "mirror/mpi-operator"})
+		Expect(err).NotTo(BeNil())
+	})
+
+})
@@ -2,17 +2,49 @@ package mpioperatortracker
 
 import (
 	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
 	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
 )
 
+// JobInfoFromMPIJob converts an MPIJob into a DRMAA2 job info. The
+// QueueName is the scheduler queue of the job (SchedulingPolicy.Queue),
+// like the queues returned by GetAllQueueNames.
 func JobInfoFromMPIJob(mpiJob *kubeflow.MPIJob) (jobInfo drmaa2interface.JobInfo) {
 	jobInfo = drmaa2interface.JobInfo{
 		ID:             mpiJob.Name,
-		Slots:          int64(*mpiJob.Spec.SlotsPerWorker * *mpiJob.Spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Replicas),
-		SubmissionTime: mpiJob.Status.StartTime.Time,
-		DispatchTime:   mpiJob.Status.StartTime.Time,
-		FinishTime:     mpiJob.Status.CompletionTime.Time,
-		WallclockTime:  mpiJob.Status.CompletionTime.Time.Sub(mpiJob.Status.StartTime.Time),
+		SubmissionTime: mpiJob.CreationTimestamp.Time,
+	}
+	jobInfo.State, jobInfo.SubState, _ = JobStateFromMPIJob(mpiJob)
+	jobInfo.JobOwner = JobOwner(mpiJob)
+	if mpiJob.Spec.RunPolicy.SchedulingPolicy != nil {
+		jobInfo.QueueName = mpiJob.Spec.RunPolicy.SchedulingPolicy.Queue
+	}
+	worker := mpiJob.Spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker]
+	if mpiJob.Spec.SlotsPerWorker != nil && worker != nil && worker.Replicas != nil {
+		jobInfo.Slots = int64(*mpiJob.Spec.SlotsPerWorker * *worker.Replicas)
+		// running (elastic) jobs report the slots of the running workers
+		if active := activeWorkers(mpiJob); active > 0 && !IsEndState(jobInfo.State) {
+			jobInfo.Slots = int64(*mpiJob.Spec.SlotsPerWorker * active)
+		}
+	}
+	// start and completion time are not set for jobs which
+	// are not yet started or not yet finished
+	if mpiJob.Status.StartTime != nil {
+		jobInfo.DispatchTime = mpiJob.Status.StartTime.Time
+	}
+	if mpiJob.Status.CompletionTime != nil {
+		jobInfo.FinishTime = mpiJob.Status.CompletionTime.Time
+		if mpiJob.Status.StartTime != nil {
+			jobInfo.WallclockTime = mpiJob.Status.CompletionTime.Time.Sub(mpiJob.Status.StartTime.Time)
+		}
 	}
 	return jobInfo
 }
+
+func activeWorkers(mpiJob *kubeflow.MPIJob) int32 {
+	worker := mpiJob.Status.ReplicaStatuses[common.ReplicaType(kubeflow.MPIReplicaTypeWorker)]
+	if worker == nil {
+		return 0
+	}
+	return worker.Active
+}
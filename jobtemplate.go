@@ -2,6 +2,7 @@ package mpioperatortracker
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -10,7 +11,6 @@ import (
 	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"k8s.io/klog"
 )
 
 const ExtensionWorkerImage = "workerImage"
@@ -19,6 +19,42 @@ const ExtensionSlotsPerWorker = "slotsPerWorker"
 const ExtensionMPIImplementation = "mpiImplementation"
 const ExtensionSSHMountPath = "sshMountPath"
 const ExtensionRunAsUser = "runAsUser"
+const ExtensionTTLSecondsAfterFinished = "ttlSecondsAfterFinished"
+const ExtensionCleanPodPolicy = "cleanPodPolicy"
+const ExtensionRetries = "retries"
+const ExtensionLauncherRestartPolicy = "launcherRestartPolicy"
+const ExtensionWorkerRestartPolicy = "workerRestartPolicy"
+const ExtensionElastic = "elastic"
+const ExtensionSlotMode = "slotMode"
+const ExtensionSlotsFromCPU = "slotsFromCPU"
+const ExtensionCoresPerSlot = "coresPerSlot"
+
+// Slot modes define how MinSlots and MaxSlots of the job template are
+// interpreted.
+const (
+	// SlotModeWorkers interprets MinSlots and MaxSlots as the number of
+	// worker replicas, each having slotsPerWorker slots (default).
+	SlotModeWorkers = "workers"
+	// SlotModeRanks interprets MinSlots and MaxSlots as the total number
+	// of MPI ranks. The number of workers is rounded up.
+	SlotModeRanks = "ranks"
+	// SlotModeRanksExact is like SlotModeRanks but requires that the
+	// ranks are a multiple of slotsPerWorker.
+	SlotModeRanksExact = "ranksExact"
+)
+
+// Environment variables set in the launcher of elastic jobs. They can be
+// passed to elastic launchers like horovodrun --min-np --max-np
+// --host-discovery-script.
+const (
+	EnvElasticMinNP           = "ELASTIC_MIN_NP"
+	EnvElasticMaxNP           = "ELASTIC_MAX_NP"
+	EnvElasticDiscoveryScript = "ELASTIC_DISCOVERY_SCRIPT"
+)
+
+// DiscoverHostsScript is the script the MPI operator mounts into the
+// launcher which lists the hosts of all running workers.
+const DiscoverHostsScript = "/etc/mpi/discover_hosts.sh"
 
 type VolumeMountSpec struct {
 	MountPath  string // path to mount volume inside the container
@@ -69,6 +105,15 @@ func GetVolumeMounts(jt drmaa2interface.JobTemplate) []VolumeMountSpec {
 			VolumeName: strings.Join(vt[1:], ":"),
 		})
 	}
+	// the volumes are named by their index, so that pod template patches
+	// can refer to them: persistent volume claims come first, then the
+	// other volumes, each ordered by mount path
+	sort.Slice(vm, func(i, j int) bool {
+		if pvcI, pvcJ := vm[i].VolumeType == "pvc", vm[j].VolumeType == "pvc"; pvcI != pvcJ {
+			return pvcI
+		}
+		return vm[i].MountPath < vm[j].MountPath
+	})
 	return vm
 }
 
@@ -110,6 +155,17 @@ func SetLauncherResourceRequestsExtension(jt drmaa2interface.JobTemplate, reques
 	return jt
 }
 
+// resourceExtensionPrefixes are the prefixes of the resource request and
+// limit extensions; the suffix is the resource name.
+var resourceExtensionPrefixes = []string{
+	"resourceLimitLauncher-",
+	"resourceLimitWorker-",
+	"resourceRequestLauncher-",
+	"resourceRequestWorker-",
+}
+
+// getResourceExtension returns the resources of the extensions with the
+// prefix. Invalid quantities are skipped.
 func getResourceExtension(jt drmaa2interface.JobTemplate, extensionPrefix string) v1.ResourceList {
 	if jt.ExtensionList == nil {
 		return nil
@@ -120,7 +176,7 @@ func getResourceExtension(jt drmaa2interface.JobTemplate, extensionPrefix string
 			resourceName := strings.TrimPrefix(k, extensionPrefix)
 			quantity, err := resource.ParseQuantity(v)
 			if err != nil {
-				fmt.Printf("Failed to parse resource quantity %s: %s\n", v, err)
+				// reported by JobTemplateWarnings
 				continue
 			}
 			requests[v1.ResourceName(resourceName)] = quantity
@@ -147,6 +203,38 @@ func GetWorkerResourceRequestExtension(jt drmaa2interface.JobTemplate) v1.Resour
 	return getResourceExtension(jt, "resourceRequestWorker-")
 }
 
+// JobTemplateWarnings returns the settings of the job template which are
+// ignored by ConvertJobTemplateToMPIJob: resource extensions with invalid
+// quantities and volume mounts of unsupported types.
+func JobTemplateWarnings(jt drmaa2interface.JobTemplate) []string {
+	var warnings []string
+	extensions := make([]string, 0, len(jt.ExtensionList))
+	for k := range jt.ExtensionList {
+		extensions = append(extensions, k)
+	}
+	sort.Strings(extensions)
+	for _, k := range extensions {
+		for _, prefix := range resourceExtensionPrefixes {
+			if !strings.HasPrefix(k, prefix) {
+				continue
+			}
+			if _, err := resource.ParseQuantity(jt.ExtensionList[k]); err != nil {
+				warnings = append(warnings, fmt.Sprintf("invalid resource quantity %s of extension %s: %v",
+					jt.ExtensionList[k], k, err))
+			}
+		}
+	}
+	for _, volumeMount := range GetVolumeMounts(jt) {
+		switch volumeMount.VolumeType {
+		case "configmap", "cm", "pvc":
+		default:
+			warnings = append(warnings, fmt.Sprintf("unsupported volume type %s of %s (only configmap, pvc allowed)",
+				volumeMount.VolumeType, volumeMount.MountPath))
+		}
+	}
+	return warnings
+}
+
 func SetWorkerImageExtension(jt drmaa2interface.JobTemplate, workerImage string) drmaa2interface.JobTemplate {
 	if jt.ExtensionList == nil {
 		jt.ExtensionList = make(map[string]string)
@@ -252,6 +340,307 @@ func GetRunAsUserExtension(jt drmaa2interface.JobTemplate) int64 {
 	return -1
 }
 
+// SetTTLSecondsAfterFinishedExtension sets the TTLSecondsAfterFinished of
+// the MPIJob. The MPI operator passes it to the launcher Job, hence the
+// launcher Job (and its pod) is removed by Kubernetes after the TTL. The
+// MPIJob itself and its state are kept; finished MPIJobs are removed by
+// the Reap method of the tracker.
+func SetTTLSecondsAfterFinishedExtension(jt drmaa2interface.JobTemplate, ttlSeconds int32) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionTTLSecondsAfterFinished] = strconv.Itoa(int(ttlSeconds))
+	return jt
+}
+
+// GetTTLSecondsAfterFinishedExtension returns the TTL of a finished job
+// or nil if not set.
+func GetTTLSecondsAfterFinishedExtension(jt drmaa2interface.JobTemplate) *int32 {
+	if jt.ExtensionList == nil {
+		return nil
+	}
+	ttl, err := strconv.ParseInt(jt.ExtensionList[ExtensionTTLSecondsAfterFinished], 10, 32)
+	if err != nil || ttl < 0 {
+		return nil
+	}
+	return toInt32(int32(ttl))
+}
+
+// SetCleanPodPolicyExtension sets which pods are removed when the job
+// finished: None keeps all pods (like failed workers for post-mortem
+// analysis), Running removes only running pods, and All removes all pods.
+func SetCleanPodPolicyExtension(jt drmaa2interface.JobTemplate, policy common.CleanPodPolicy) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionCleanPodPolicy] = string(policy)
+	return jt
+}
+
+// GetCleanPodPolicyExtension returns the clean pod policy of the job
+// template. The default is Running.
+func GetCleanPodPolicyExtension(jt drmaa2interface.JobTemplate) (common.CleanPodPolicy, error) {
+	if jt.ExtensionList == nil || jt.ExtensionList[ExtensionCleanPodPolicy] == "" {
+		return common.CleanPodPolicyRunning, nil
+	}
+	switch policy := common.CleanPodPolicy(jt.ExtensionList[ExtensionCleanPodPolicy]); policy {
+	case common.CleanPodPolicyNone, common.CleanPodPolicyRunning, common.CleanPodPolicyAll:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported clean pod policy %q (None, Running, or All)", policy)
+	}
+}
+
+// SetRetriesExtension sets how often a failed job is restarted. It is
+// the BackoffLimit of the MPIJob.
+func SetRetriesExtension(jt drmaa2interface.JobTemplate, retries int32) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionRetries] = strconv.Itoa(int(retries))
+	return jt
+}
+
+// GetRetriesExtension returns how often a failed job is restarted.
+// The default is 0.
+func GetRetriesExtension(jt drmaa2interface.JobTemplate) (int32, error) {
+	if jt.ExtensionList == nil || jt.ExtensionList[ExtensionRetries] == "" {
+		return 0, nil
+	}
+	retries, err := strconv.ParseInt(jt.ExtensionList[ExtensionRetries], 10, 32)
+	if err != nil || retries < 0 {
+		return 0, fmt.Errorf("retries must be a positive number: %s",
+			jt.ExtensionList[ExtensionRetries])
+	}
+	return int32(retries), nil
+}
+
+// SetLauncherRestartPolicyExtension sets the restart policy of the launcher.
+func SetLauncherRestartPolicyExtension(jt drmaa2interface.JobTemplate, policy common.RestartPolicy) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionLauncherRestartPolicy] = string(policy)
+	return jt
+}
+
+// GetLauncherRestartPolicyExtension returns the restart policy of the
+// launcher. The default is Never.
+func GetLauncherRestartPolicyExtension(jt drmaa2interface.JobTemplate) common.RestartPolicy {
+	return getRestartPolicyExtension(jt, ExtensionLauncherRestartPolicy)
+}
+
+// SetWorkerRestartPolicyExtension sets the restart policy of the workers.
+func SetWorkerRestartPolicyExtension(jt drmaa2interface.JobTemplate, policy common.RestartPolicy) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionWorkerRestartPolicy] = string(policy)
+	return jt
+}
+
+// GetWorkerRestartPolicyExtension returns the restart policy of the
+// workers. The default is Never.
+func GetWorkerRestartPolicyExtension(jt drmaa2interface.JobTemplate) common.RestartPolicy {
+	return getRestartPolicyExtension(jt, ExtensionWorkerRestartPolicy)
+}
+
+func getRestartPolicyExtension(jt drmaa2interface.JobTemplate, extension string) common.RestartPolicy {
+	if jt.ExtensionList == nil || jt.ExtensionList[extension] == "" {
+		return common.RestartPolicyNever
+	}
+	return common.RestartPolicy(jt.ExtensionList[extension])
+}
+
+// SetElasticExtension turns the MinSlots..MaxSlots range of the job
+// template into an elastic job. MaxSlots workers are requested and the
+// launcher discovers the running workers. With gang scheduling the
+// SchedulingPolicy.MinAvailable is set to MinSlots workers (and the
+// launcher), so that the job starts as soon as they can be scheduled.
+// This requires an MPI operator which honors MinAvailable (see
+// Capabilities.MinAvailableSupported); older operators gang schedule all
+// MaxSlots workers.
+func SetElasticExtension(jt drmaa2interface.JobTemplate, elastic bool) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionElastic] = strconv.FormatBool(elastic)
+	return jt
+}
+
+func GetElasticExtension(jt drmaa2interface.JobTemplate) bool {
+	if jt.ExtensionList == nil {
+		return false
+	}
+	elastic, _ := strconv.ParseBool(jt.ExtensionList[ExtensionElastic])
+	return elastic
+}
+
+// SetSlotModeExtension sets how MinSlots and MaxSlots are interpreted
+// (SlotModeWorkers, SlotModeRanks, or SlotModeRanksExact).
+func SetSlotModeExtension(jt drmaa2interface.JobTemplate, slotMode string) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionSlotMode] = slotMode
+	return jt
+}
+
+// GetSlotModeExtension returns the slot mode of the job template. The
+// default is SlotModeWorkers.
+func GetSlotModeExtension(jt drmaa2interface.JobTemplate) string {
+	if jt.ExtensionList == nil || jt.ExtensionList[ExtensionSlotMode] == "" {
+		return SlotModeWorkers
+	}
+	return jt.ExtensionList[ExtensionSlotMode]
+}
+
+// WorkerReplicas returns the min. and max. number of worker replicas for
+// MinSlots and MaxSlots of the job template depending on its slot mode.
+// When MaxSlots is not set, max is min.
+func WorkerReplicas(jt drmaa2interface.JobTemplate, slotsPerWorker int32) (min, max int64, err error) {
+	minSlots, maxSlots := jt.MinSlots, jt.MaxSlots
+	if maxSlots < minSlots {
+		maxSlots = minSlots
+	}
+	switch mode := GetSlotModeExtension(jt); mode {
+	case SlotModeWorkers:
+		return minSlots, maxSlots, nil
+	case SlotModeRanks, SlotModeRanksExact:
+		if slotsPerWorker <= 0 {
+			return 0, 0, fmt.Errorf("slotsPerWorker must be > 0 in slot mode %s", mode)
+		}
+		spw := int64(slotsPerWorker)
+		if mode == SlotModeRanksExact && (minSlots%spw != 0 || maxSlots%spw != 0) {
+			return 0, 0, fmt.Errorf("MinSlots (%d) and MaxSlots (%d) must be a multiple of slotsPerWorker (%d)",
+				jt.MinSlots, jt.MaxSlots, spw)
+		}
+		return (minSlots + spw - 1) / spw, (maxSlots + spw - 1) / spw, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown slot mode %q (%s, %s, or %s)",
+			mode, SlotModeWorkers, SlotModeRanks, SlotModeRanksExact)
+	}
+}
+
+// SetSlotsFromCPUExtension derives the slots per worker from the CPU
+// request of the workers (one slot per requested core).
+func SetSlotsFromCPUExtension(jt drmaa2interface.JobTemplate, slotsFromCPU bool) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionSlotsFromCPU] = strconv.FormatBool(slotsFromCPU)
+	return jt
+}
+
+func GetSlotsFromCPUExtension(jt drmaa2interface.JobTemplate) bool {
+	if jt.ExtensionList == nil {
+		return false
+	}
+	slotsFromCPU, _ := strconv.ParseBool(jt.ExtensionList[ExtensionSlotsFromCPU])
+	return slotsFromCPU
+}
+
+// SetCoresPerSlotExtension derives the CPU request of the workers from
+// the slots per worker multiplied by the given cores (like "1" or "500m").
+func SetCoresPerSlotExtension(jt drmaa2interface.JobTemplate, coresPerSlot resource.Quantity) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionCoresPerSlot] = coresPerSlot.String()
+	return jt
+}
+
+// GetCoresPerSlotExtension returns the cores per slot or nil if not set.
+func GetCoresPerSlotExtension(jt drmaa2interface.JobTemplate) (*resource.Quantity, error) {
+	if jt.ExtensionList == nil || jt.ExtensionList[ExtensionCoresPerSlot] == "" {
+		return nil, nil
+	}
+	coresPerSlot, err := resource.ParseQuantity(jt.ExtensionList[ExtensionCoresPerSlot])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cores per slot %s: %v", jt.ExtensionList[ExtensionCoresPerSlot], err)
+	}
+	return &coresPerSlot, nil
+}
+
+// SlotsPerWorkerAndRequests returns the slots per worker and the resource
+// requests of the workers. When the slotsFromCPU extension is set the
+// slots are the requested worker cores (rounded down). When the
+// coresPerSlot extension is set the CPU request is the slots per worker
+// multiplied by the cores per slot. Both extensions can't be combined.
+func SlotsPerWorkerAndRequests(jt drmaa2interface.JobTemplate) (int32, v1.ResourceList, error) {
+	slotsPerWorker := GetSlotsPerWorkerExtension(jt)
+	requests := GetWorkerResourceRequestExtension(jt)
+	coresPerSlot, err := GetCoresPerSlotExtension(jt)
+	if err != nil {
+		return 0, nil, err
+	}
+	slotsFromCPU := GetSlotsFromCPUExtension(jt)
+	switch {
+	case slotsFromCPU && coresPerSlot != nil:
+		return 0, nil, fmt.Errorf("%s and %s can't be combined", ExtensionSlotsFromCPU, ExtensionCoresPerSlot)
+	case slotsFromCPU:
+		cpu, exists := requests[v1.ResourceCPU]
+		if !exists {
+			return 0, nil, fmt.Errorf("%s requires a worker CPU request", ExtensionSlotsFromCPU)
+		}
+		slots := cpu.MilliValue() / 1000
+		if slots < 1 {
+			return 0, nil, fmt.Errorf("worker CPU request %s is less than one slot", cpu.String())
+		}
+		slotsPerWorker = int32(slots)
+	case coresPerSlot != nil:
+		if _, exists := requests[v1.ResourceCPU]; exists {
+			return 0, nil, fmt.Errorf("%s can't be combined with a worker CPU request", ExtensionCoresPerSlot)
+		}
+		if requests == nil {
+			requests = make(v1.ResourceList)
+		}
+		requests[v1.ResourceCPU] = *resource.NewMilliQuantity(
+			coresPerSlot.MilliValue()*int64(slotsPerWorker), resource.DecimalSI)
+	}
+	return slotsPerWorker, requests, nil
+}
+
+// MergeJobTemplateDefaults returns the job template where unset fields
+// are taken from the defaults. Supported are JobCategory, MinSlots,
+// MaxSlots, QueueName, WorkingDirectory, and entries of JobEnvironment,
+// ExtensionList, and StageInFiles which are not set in the job template.
+func MergeJobTemplateDefaults(jt, defaults drmaa2interface.JobTemplate) drmaa2interface.JobTemplate {
+	if jt.JobCategory == "" {
+		jt.JobCategory = defaults.JobCategory
+	}
+	if jt.MinSlots == 0 && jt.MaxSlots == 0 {
+		jt.MinSlots = defaults.MinSlots
+		jt.MaxSlots = defaults.MaxSlots
+	}
+	if jt.QueueName == "" {
+		jt.QueueName = defaults.QueueName
+	}
+	if jt.WorkingDirectory == "" {
+		jt.WorkingDirectory = defaults.WorkingDirectory
+	}
+	jt.JobEnvironment = mergeStringMaps(jt.JobEnvironment, defaults.JobEnvironment)
+	jt.ExtensionList = mergeStringMaps(jt.ExtensionList, defaults.ExtensionList)
+	jt.StageInFiles = mergeStringMaps(jt.StageInFiles, defaults.StageInFiles)
+	return jt
+}
+
+// mergeStringMaps returns a copy of values where missing keys are
+// added from defaults.
+func mergeStringMaps(values, defaults map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return values
+	}
+	merged := make(map[string]string, len(values)+len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
 func ConvertJobTemplateToMPIJob(jt drmaa2interface.JobTemplate) (kubeflow.MPIJobSpec, error) {
 	if jt.JobCategory == "" {
 		return kubeflow.MPIJobSpec{}, fmt.Errorf("JobCategory is required. It specifies the MPI launcher image")
@@ -273,16 +662,47 @@ func ConvertJobTemplateToMPIJob(jt drmaa2interface.JobTemplate) (kubeflow.MPIJob
 		}
 	}
 	sshAuthMountPath := GetSSHMountPathExtension(jt)
-	slotsPerWorker := GetSlotsPerWorkerExtension(jt)
+	slotsPerWorker, workerRequests, err := SlotsPerWorkerAndRequests(jt)
+	if err != nil {
+		return kubeflow.MPIJobSpec{}, err
+	}
 	mpiImplementation := GetMPIImplementationExtension(jt)
+	cleanPodPolicy, err := GetCleanPodPolicyExtension(jt)
+	if err != nil {
+		return kubeflow.MPIJobSpec{}, err
+	}
+	retries, err := GetRetriesExtension(jt)
+	if err != nil {
+		return kubeflow.MPIJobSpec{}, err
+	}
+	launcherRestartPolicy := GetLauncherRestartPolicyExtension(jt)
+	workerRestartPolicy := GetWorkerRestartPolicyExtension(jt)
 
-	workerReplicas := jt.MinSlots
-	if jt.MaxSlots > jt.MinSlots {
-		workerReplicas = jt.MaxSlots
+	minWorkers, workerReplicas, err := WorkerReplicas(jt, slotsPerWorker)
+	if err != nil {
+		return kubeflow.MPIJobSpec{}, err
 	}
 	if workerReplicas == 0 {
 		return kubeflow.MPIJobSpec{}, fmt.Errorf("MinSlots or MaxSlots is required. It specifies the number of workers")
 	}
+	elastic := GetElasticExtension(jt)
+	if elastic && (jt.MinSlots <= 0 || jt.MaxSlots < jt.MinSlots) {
+		return kubeflow.MPIJobSpec{}, fmt.Errorf("elastic jobs require 0 < MinSlots <= MaxSlots")
+	}
+
+	env := environmentFromJobTemplate(jt)
+	launcherEnv := env
+	if elastic {
+		minNP, maxNP := jt.MinSlots, jt.MaxSlots
+		if GetSlotModeExtension(jt) == SlotModeWorkers {
+			minNP, maxNP = minNP*int64(slotsPerWorker), maxNP*int64(slotsPerWorker)
+		}
+		launcherEnv = append(append([]v1.EnvVar{}, env...),
+			v1.EnvVar{Name: EnvElasticMinNP, Value: strconv.FormatInt(minNP, 10)},
+			v1.EnvVar{Name: EnvElasticMaxNP, Value: strconv.FormatInt(maxNP, 10)},
+			v1.EnvVar{Name: EnvElasticDiscoveryScript, Value: DiscoverHostsScript},
+		)
+	}
 
 	launcherTemplate := v1.PodTemplateSpec{
 		Spec: v1.PodSpec{
@@ -293,6 +713,7 @@ func ConvertJobTemplateToMPIJob(jt drmaa2interface.JobTemplate) (kubeflow.MPIJob
 					Command:    []string{jt.RemoteCommand},
 					Args:       jt.Args,
 					WorkingDir: jt.WorkingDirectory,
+					Env:        launcherEnv,
 					Resources: v1.ResourceRequirements{
 						Requests: GetLauncherResourceRequestExtension(jt),
 						Limits:   GetLauncherResourceLimitExtension(jt),
@@ -315,8 +736,9 @@ func ConvertJobTemplateToMPIJob(jt drmaa2interface.JobTemplate) (kubeflow.MPIJob
 					Command:    workerCommand,
 					Args:       workerArgs,
 					WorkingDir: jt.WorkingDirectory,
+					Env:        env,
 					Resources: v1.ResourceRequirements{
-						Requests: GetWorkerResourceRequestExtension(jt),
+						Requests: workerRequests,
 						Limits:   GetWorkerResourceLimitExtension(jt),
 					},
 				},
@@ -414,34 +836,76 @@ func ConvertJobTemplateToMPIJob(jt drmaa2interface.JobTemplate) (kubeflow.MPIJob
 					},
 				})
 		default:
-			klog.Errorf("Unsupported volume type (only configmap, pvc allowed): %s", volumeMount.VolumeType)
+			// reported by JobTemplateWarnings
 		}
 	}
 
+	// generic patches are applied on top of everything else
+	launcherTemplate, err = PatchPodTemplate(launcherTemplate, GetLauncherPodTemplatePatchExtension(jt))
+	if err != nil {
+		return kubeflow.MPIJobSpec{}, fmt.Errorf("launcher: %v", err)
+	}
+	workerTemplate, err = PatchPodTemplate(workerTemplate, GetWorkerPodTemplatePatchExtension(jt))
+	if err != nil {
+		return kubeflow.MPIJobSpec{}, fmt.Errorf("worker: %v", err)
+	}
+
 	spec := kubeflow.MPIJobSpec{
 		RunPolicy: common.RunPolicy{
-			BackoffLimit:   toInt32(0),
-			CleanPodPolicy: newCleanPodPolicy(common.CleanPodPolicyRunning),
+			BackoffLimit:            toInt32(retries),
+			CleanPodPolicy:          newCleanPodPolicy(cleanPodPolicy),
+			TTLSecondsAfterFinished: GetTTLSecondsAfterFinishedExtension(jt),
 		},
 		MPIImplementation: mpiImplementation,
 		SlotsPerWorker:    toInt32(slotsPerWorker),
 		SSHAuthMountPath:  sshAuthMountPath,
 		MPIReplicaSpecs: map[kubeflow.MPIReplicaType]*common.ReplicaSpec{
 			kubeflow.MPIReplicaTypeLauncher: {
-				RestartPolicy: common.RestartPolicyNever,
+				RestartPolicy: launcherRestartPolicy,
 				Template:      launcherTemplate,
 			},
 			kubeflow.MPIReplicaTypeWorker: {
-				RestartPolicy: common.RestartPolicyNever,
+				RestartPolicy: workerRestartPolicy,
 				Replicas:      toInt32(int32(workerReplicas)),
 				Template:      workerTemplate,
 			},
 		},
 	}
 
+	if jt.QueueName != "" {
+		spec.RunPolicy.SchedulingPolicy = &common.SchedulingPolicy{
+			Queue: jt.QueueName,
+		}
+	}
+	if elastic {
+		// gang scheduling of the launcher and MinSlots workers; operators
+		// before v0.4.0 ignore it and use all worker replicas as min.
+		// member of the PodGroup
+		if spec.RunPolicy.SchedulingPolicy == nil {
+			spec.RunPolicy.SchedulingPolicy = &common.SchedulingPolicy{}
+		}
+		spec.RunPolicy.SchedulingPolicy.MinAvailable = toInt32(int32(minWorkers) + 1)
+	}
+
 	return spec, nil
 }
 
+// environmentFromJobTemplate converts the JobEnvironment into a
+// container environment sorted by the variable names.
+func environmentFromJobTemplate(jt drmaa2interface.JobTemplate) []v1.EnvVar {
+	if len(jt.JobEnvironment) == 0 {
+		return nil
+	}
+	env := make([]v1.EnvVar, 0, len(jt.JobEnvironment))
+	for name, value := range jt.JobEnvironment {
+		env = append(env, v1.EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+	return env
+}
+
 func newCleanPodPolicy(v common.CleanPodPolicy) *common.CleanPodPolicy {
 	return &v
 }
@@ -27,6 +27,153 @@ var _ = Describe("Mpioperator", func() {
 			Expect(err).To(BeNil())
 		})
 
+		It("should set the queue name as scheduling policy", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			basicJobTemplate.QueueName = "volcano-queue"
+			spec, err := ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			Expect(spec.RunPolicy.SchedulingPolicy).NotTo(BeNil())
+			Expect(spec.RunPolicy.SchedulingPolicy.Queue).To(Equal("volcano-queue"))
+		})
+
+		It("should set the clean pod policy and the retries", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			spec, err := ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			Expect(*spec.RunPolicy.CleanPodPolicy).To(Equal(common.CleanPodPolicyRunning))
+			Expect(*spec.RunPolicy.BackoffLimit).To(BeNumerically("==", 0))
+
+			basicJobTemplate = SetCleanPodPolicyExtension(basicJobTemplate, common.CleanPodPolicyNone)
+			basicJobTemplate = SetRetriesExtension(basicJobTemplate, 3)
+			spec, err = ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			Expect(*spec.RunPolicy.CleanPodPolicy).To(Equal(common.CleanPodPolicyNone))
+			Expect(*spec.RunPolicy.BackoffLimit).To(BeNumerically("==", 3))
+		})
+
+		It("should set the restart policies of launcher and workers", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			basicJobTemplate = SetWorkerRestartPolicyExtension(basicJobTemplate, common.RestartPolicyOnFailure)
+			spec, err := ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			Expect(spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher].RestartPolicy).To(Equal(common.RestartPolicyNever))
+			Expect(spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].RestartPolicy).To(Equal(common.RestartPolicyOnFailure))
+		})
+
+		It("should convert an elastic job", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			basicJobTemplate.MaxSlots = 4
+			basicJobTemplate = SetSlotsPerWorkerExtension(basicJobTemplate, 2)
+			basicJobTemplate = SetElasticExtension(basicJobTemplate, true)
+			spec, err := ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			Expect(*spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Replicas).To(BeNumerically("==", 4))
+			Expect(*spec.RunPolicy.SchedulingPolicy.MinAvailable).To(BeNumerically("==", 3))
+			launcherEnv := spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher].Template.Spec.Containers[0].Env
+			Expect(launcherEnv).To(ContainElement(corev1.EnvVar{Name: EnvElasticMinNP, Value: "4"}))
+			Expect(launcherEnv).To(ContainElement(corev1.EnvVar{Name: EnvElasticMaxNP, Value: "8"}))
+			Expect(launcherEnv).To(ContainElement(corev1.EnvVar{Name: EnvElasticDiscoveryScript, Value: DiscoverHostsScript}))
+			Expect(spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Template.Spec.Containers[0].Env).To(BeEmpty())
+
+			job := NewMPIJob(spec)
+			job.Status.Conditions = []common.JobCondition{{Type: common.JobRunning}}
+			job.Status.ReplicaStatuses = map[common.ReplicaType]*common.ReplicaStatus{
+				common.ReplicaType(kubeflow.MPIReplicaTypeWorker): {Active: 3},
+			}
+			Expect(JobInfoFromMPIJob(&job).Slots).To(BeNumerically("==", 6))
+		})
+
+		It("should derive the workers from the ranks in slot mode ranks", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 6
+			basicJobTemplate = SetSlotsPerWorkerExtension(basicJobTemplate, 4)
+			basicJobTemplate = SetSlotModeExtension(basicJobTemplate, SlotModeRanks)
+			spec, err := ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			Expect(*spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Replicas).To(BeNumerically("==", 2))
+
+			_, err = ConvertJobTemplateToMPIJob(SetSlotModeExtension(basicJobTemplate, SlotModeRanksExact))
+			Expect(err).NotTo(BeNil())
+			basicJobTemplate.MinSlots = 8
+			spec, err = ConvertJobTemplateToMPIJob(SetSlotModeExtension(basicJobTemplate, SlotModeRanksExact))
+			Expect(err).To(BeNil())
+			Expect(*spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Replicas).To(BeNumerically("==", 2))
+			job := NewMPIJob(spec)
+			Expect(JobInfoFromMPIJob(&job).Slots).To(BeNumerically("==", 8))
+
+			// legacy mode: slots are workers
+			spec, err = ConvertJobTemplateToMPIJob(SetSlotModeExtension(basicJobTemplate, SlotModeWorkers))
+			Expect(err).To(BeNil())
+			Expect(*spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Replicas).To(BeNumerically("==", 8))
+
+			_, err = ConvertJobTemplateToMPIJob(SetSlotModeExtension(basicJobTemplate, "cores"))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should derive slots per worker and CPU requests from each other", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			jt := SetWorkerResourceRequestsExtension(basicJobTemplate, corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse("3500m"),
+			})
+			spec, err := ConvertJobTemplateToMPIJob(SetSlotsFromCPUExtension(jt, true))
+			Expect(err).To(BeNil())
+			Expect(*spec.SlotsPerWorker).To(BeNumerically("==", 3))
+
+			jt = SetSlotsPerWorkerExtension(basicJobTemplate, 4)
+			jt = SetCoresPerSlotExtension(jt, resource.MustParse("500m"))
+			spec, err = ConvertJobTemplateToMPIJob(jt)
+			Expect(err).To(BeNil())
+			cpu := spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]
+			Expect(cpu.String()).To(Equal("2"))
+
+			_, err = ConvertJobTemplateToMPIJob(SetSlotsFromCPUExtension(jt, true))
+			Expect(err).NotTo(BeNil())
+			_, err = ConvertJobTemplateToMPIJob(SetSlotsFromCPUExtension(basicJobTemplate, true))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should apply pod template patches", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			basicJobTemplate = SetLauncherPodTemplatePatchExtension(basicJobTemplate,
+				`[{"op": "add", "path": "/spec/hostIPC", "value": true}]`)
+			basicJobTemplate = SetWorkerPodTemplatePatchExtension(basicJobTemplate, `
+spec:
+  dnsPolicy: None
+  containers:
+  - name: sidecar
+    image: busybox
+  - name: worker
+    workingDir: /data
+`)
+			spec, err := ConvertJobTemplateToMPIJob(basicJobTemplate)
+			Expect(err).To(BeNil())
+			launcher := spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher].Template.Spec
+			Expect(launcher.HostIPC).To(BeTrue())
+			Expect(launcher.Containers).To(HaveLen(1))
+			worker := spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Template.Spec
+			Expect(worker.DNSPolicy).To(Equal(corev1.DNSNone))
+			Expect(worker.Containers).To(HaveLen(2))
+			for _, container := range worker.Containers {
+				if container.Name == "worker" {
+					Expect(container.Image).To(Equal("mpi-launcher"))
+					Expect(container.WorkingDir).To(Equal("/data"))
+				}
+			}
+		})
+
 		It("should convert an example job", func() {
 			var basicJobTemplate drmaa2interface.JobTemplate
 			basicJobTemplate.JobCategory = "rongou/tensorflow_benchmarks:latest"
@@ -169,6 +316,87 @@ var _ = Describe("Mpioperator", func() {
 			Expect(err).NotTo(BeNil())
 		})
 
+		It("should fail to convert invalid clean pod policies and retries", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			_, err := ConvertJobTemplateToMPIJob(
+				SetCleanPodPolicyExtension(basicJobTemplate, "Sometimes"))
+			Expect(err).NotTo(BeNil())
+			_, err = ConvertJobTemplateToMPIJob(
+				SetRetriesExtension(basicJobTemplate, -1))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should fail to convert an elastic job without slot range", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MaxSlots = 4
+			_, err := ConvertJobTemplateToMPIJob(SetElasticExtension(basicJobTemplate, true))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should fail to convert invalid pod template patches", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			_, err := ConvertJobTemplateToMPIJob(SetWorkerPodTemplatePatchExtension(basicJobTemplate,
+				`[{"op": "replace", "path": "/spec/unknown/field", "value": 1}]`))
+			Expect(err).NotTo(BeNil())
+			_, err = ConvertJobTemplateToMPIJob(SetLauncherPodTemplatePatchExtension(basicJobTemplate,
+				`"spec"`))
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should validate restart policies for the MPIJob API version", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			validate := func(jt drmaa2interface.JobTemplate, apiVersion string) error {
+				spec, err := ConvertJobTemplateToMPIJob(jt)
+				Expect(err).To(BeNil())
+				job := NewMPIJob(spec)
+				return ValidateMPIJob(&job, apiVersion)
+			}
+			Expect(validate(basicJobTemplate, APIVersionV2beta1)).To(Succeed())
+			Expect(validate(SetWorkerRestartPolicyExtension(basicJobTemplate, common.RestartPolicyOnFailure),
+				APIVersionV2beta1)).To(Succeed())
+
+			always := SetLauncherRestartPolicyExtension(basicJobTemplate, common.RestartPolicyAlways)
+			Expect(validate(always, APIVersionV2beta1)).NotTo(Succeed())
+			exitCode := SetWorkerRestartPolicyExtension(basicJobTemplate, common.RestartPolicyExitCode)
+			Expect(validate(exitCode, APIVersionV2beta1)).NotTo(Succeed())
+			Expect(validate(SetWorkerRestartPolicyExtension(basicJobTemplate, "Sometimes"),
+				APIVersionV2beta1)).NotTo(Succeed())
+
+			// the v1 operator validates its jobs itself
+			Expect(validate(always, APIVersionV1)).To(Succeed())
+			Expect(validate(exitCode, APIVersionV1)).To(Succeed())
+		})
+
+		It("should report ignored invalid resources and volume types", func() {
+			var basicJobTemplate drmaa2interface.JobTemplate
+			basicJobTemplate.JobCategory = "mpi-launcher"
+			basicJobTemplate.MinSlots = 2
+			Expect(JobTemplateWarnings(basicJobTemplate)).To(BeEmpty())
+
+			jt := SetWorkerResourceRequestsExtension(basicJobTemplate, nil)
+			jt.ExtensionList["resourceRequestWorker-memory"] = "lots"
+			jt = SetVolumeMounts(jt, []VolumeMountSpec{
+				{VolumeType: "pvc", VolumeName: "data", MountPath: "/data"},
+				{VolumeType: "secret", VolumeName: "key", MountPath: "/key"},
+			})
+			spec, err := ConvertJobTemplateToMPIJob(jt)
+			Expect(err).To(BeNil())
+			launcher := spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher]
+			Expect(launcher.Template.Spec.Volumes).To(HaveLen(1))
+
+			warnings := JobTemplateWarnings(jt)
+			Expect(warnings).To(HaveLen(2))
+			Expect(warnings[0]).To(ContainSubstring("resourceRequestWorker-memory"))
+			Expect(warnings[1]).To(ContainSubstring("secret"))
+		})
+
 	})
 
 })
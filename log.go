@@ -0,0 +1,38 @@
+package mpioperatortracker
+
+import (
+	"github.com/go-logr/logr"
+)
+
+// Verbosity levels of the tracker logs. Errors and warnings (like jobs
+// which don't fit on any node) are logged at level 0. The verbosity is
+// configured in the logger (like funcr.Options.Verbosity).
+const (
+	// LogLevelOperations logs job operations like submission,
+	// termination, and deletion.
+	LogLevelOperations = 1
+	// LogLevelDebug logs details like the progress of array job
+	// submissions.
+	LogLevelDebug = 2
+)
+
+// Keys of the structured fields of the tracker logs.
+const (
+	LogKeyJobID     = "jobID"
+	LogKeyNamespace = "namespace"
+	LogKeyOperation = "operation"
+)
+
+// jobLogger returns the logger for an operation on a job. jobID is empty
+// when the job is not yet created. Without a configured logger the logs
+// are discarded.
+func (t *MPIOperatorTracker) jobLogger(jobID, operation string) logr.Logger {
+	if t.logger.GetSink() == nil {
+		return logr.Discard()
+	}
+	logger := t.logger.WithValues(LogKeyNamespace, t.Namespace(), LogKeyOperation, operation)
+	if jobID != "" {
+		logger = logger.WithValues(LogKeyJobID, jobID)
+	}
+	return logger
+}
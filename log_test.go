@@ -0,0 +1,86 @@
+package mpioperatortracker
+
+import (
+	"github.com/dgruber/drmaa2interface"
+	"github.com/go-logr/logr/funcr"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Logging", func() {
+
+	var logs []string
+
+	newTracker := func(verbosity int) *MPIOperatorTracker {
+		logs = nil
+		return &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(
+				newFakeMPIJob("succeeded", common.JobCreated, common.JobRunning, common.JobSucceeded),
+			),
+			kubeClient: k8sfake.NewSimpleClientset(),
+			logger: funcr.New(func(prefix, args string) {
+				logs = append(logs, args)
+			}, funcr.Options{Verbosity: verbosity}),
+		}
+	}
+
+	jobTemplate := drmaa2interface.JobTemplate{
+		JobCategory: "mpi-pi",
+		MinSlots:    2,
+		Extension: drmaa2interface.Extension{
+			ExtensionList: map[string]string{"resourceLimitWorker-cpu": "many"},
+		},
+	}
+
+	It("should log warnings with the namespace and operation", func() {
+		tracker := newTracker(0)
+		_, err := tracker.AddJob(jobTemplate)
+		Expect(err).To(BeNil())
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0]).To(ContainSubstring(`"msg"="ignored job template setting"`))
+		Expect(logs[0]).To(ContainSubstring(`"namespace"="default"`))
+		Expect(logs[0]).To(ContainSubstring(`"operation"="submit"`))
+		Expect(logs[0]).To(ContainSubstring("resourceLimitWorker-cpu"))
+	})
+
+	It("should log job operations with a higher verbosity", func() {
+		tracker := newTracker(LogLevelOperations)
+		_, err := tracker.AddJob(jobTemplate)
+		Expect(err).To(BeNil())
+		Expect(logs).To(HaveLen(2))
+		Expect(logs[0]).To(ContainSubstring(`"msg"="submitted job"`))
+
+		Expect(tracker.DeleteJob("succeeded")).To(BeNil())
+		Expect(logs).To(HaveLen(3))
+		Expect(logs[2]).To(ContainSubstring(`"msg"="deleted job"`))
+		Expect(logs[2]).To(ContainSubstring(`"operation"="delete"`))
+		Expect(logs[2]).To(ContainSubstring(`"jobID"="succeeded"`))
+	})
+
+	It("should warn about elastic jobs an old operator gang schedules with all workers", func() {
+		tracker := newTracker(0)
+		tracker.capabilities = Capabilities{
+			OperatorImage:   "mpioperator/mpi-operator:0.3.0",
+			OperatorVersion: "0.3.0",
+		}
+		_, err := tracker.AddJob(SetElasticExtension(drmaa2interface.JobTemplate{
+			JobCategory: "mpi-pi",
+			MinSlots:    2,
+			MaxSlots:    4,
+		}, true))
+		Expect(err).To(BeNil())
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0]).To(ContainSubstring("gang schedules elastic jobs with all workers"))
+		Expect(logs[0]).To(ContainSubstring(`"operatorVersion"="0.3.0"`))
+	})
+
+	It("should discard logs without a logger", func() {
+		tracker := &MPIOperatorTracker{clientset: fake.NewSimpleClientset()}
+		_, err := tracker.AddJob(jobTemplate)
+		Expect(err).To(BeNil())
+	})
+
+})
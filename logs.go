@@ -0,0 +1,59 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LauncherPod returns the most recently created launcher pod of the job.
+// When the launcher was restarted older pods may still exist.
+func LauncherPod(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string) (*v1.Pod, error) {
+	selector := labels.SelectorFromSet(labels.Set{
+		common.JobNameLabel: jobName,
+		common.JobRoleLabel: "launcher",
+	}).String()
+	pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx,
+		metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list launcher pods of job %s: %v", jobName, err)
+	}
+	var launcher *v1.Pod
+	for i := range pods.Items {
+		if launcher == nil || launcher.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			launcher = &pods.Items[i]
+		}
+	}
+	if launcher == nil {
+		return nil, fmt.Errorf("no launcher pod found for job %s", jobName)
+	}
+	return launcher, nil
+}
+
+// GetJobOutput returns the log stream of the launcher pod of the job,
+// which contains the output of mpirun. With follow the stream is kept
+// open until the launcher terminates. The caller must close the stream.
+func GetJobOutput(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string, follow bool) (io.ReadCloser, error) {
+	launcher, err := LauncherPod(ctx, kubeClient, namespace, jobName)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := kubeClient.CoreV1().Pods(namespace).GetLogs(launcher.Name,
+		&v1.PodLogOptions{Follow: follow}).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs of launcher pod %s: %v", launcher.Name, err)
+	}
+	return stream, nil
+}
+
+// JobOutput returns the output of the job, which is the log of its
+// launcher pod. See GetJobOutput.
+func (t *MPIOperatorTracker) JobOutput(jobID string, follow bool) (io.ReadCloser, error) {
+	return GetJobOutput(context.Background(), t.kubeClient, t.Namespace(), jobID, follow)
+}
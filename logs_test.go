@@ -0,0 +1,59 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"io"
+	"time"
+
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newFakeLauncherPod(name, jobName string, created time.Time) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				common.JobNameLabel: jobName,
+				common.JobRoleLabel: "launcher",
+			},
+		},
+	}
+}
+
+var _ = Describe("Job output", func() {
+
+	It("should return the output of the latest launcher pod", func() {
+		now := time.Now()
+		tracker := &MPIOperatorTracker{
+			kubeClient: k8sfake.NewSimpleClientset(
+				newFakeLauncherPod("pi-launcher-1", "pi", now.Add(-time.Minute)),
+				newFakeLauncherPod("pi-launcher-2", "pi", now),
+				newFakeLauncherPod("other-launcher", "other", now.Add(time.Minute)),
+			),
+		}
+		launcher, err := LauncherPod(context.Background(), tracker.kubeClient, "default", "pi")
+		Expect(err).To(BeNil())
+		Expect(launcher.Name).To(Equal("pi-launcher-2"))
+
+		stream, err := tracker.JobOutput("pi", false)
+		Expect(err).To(BeNil())
+		defer stream.Close()
+		output, err := io.ReadAll(stream)
+		Expect(err).To(BeNil())
+		Expect(string(output)).To(Equal("fake logs"))
+	})
+
+	It("should fail when the job has no launcher pod", func() {
+		tracker := &MPIOperatorTracker{kubeClient: k8sfake.NewSimpleClientset()}
+		_, err := tracker.JobOutput("pi", false)
+		Expect(err).NotTo(BeNil())
+	})
+
+})
@@ -0,0 +1,415 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const metricsNamespace = "mpioperatortracker"
+
+// metricsRefreshTimeout is the max. time for listing the jobs of a
+// namespace when the metrics are collected.
+const metricsRefreshTimeout = 10 * time.Second
+
+// Metrics are the Prometheus metrics of MPI jobs. They are created with
+// NewMetrics and passed to trackers with the Metrics parameter. The
+// submission counter and the Kubernetes API latency are recorded when
+// they happen; all other metrics are updated on each collection by
+// listing the jobs of the namespaces of the trackers. Jobs which finish
+// and are removed (like by Reap) between two collections are not counted.
+type Metrics struct {
+	// Category returns the category label of a job. It must return a
+	// small set of values, as each value creates new time series. nil
+	// uses JobCategory. It needs to be set before the metrics are used.
+	Category func(job *kubeflow.MPIJob) string
+
+	submitted  *prometheus.CounterVec
+	succeeded  *prometheus.CounterVec
+	failed     *prometheus.CounterVec
+	queueWait  *prometheus.HistogramVec
+	runtime    *prometheus.HistogramVec
+	running    *prometheus.GaugeVec
+	slots      *prometheus.GaugeVec
+	apiLatency *prometheus.HistogramVec
+
+	// since is the creation time; jobs which started or finished
+	// before are not observed
+	since time.Time
+
+	sync.Mutex
+	// refreshers list the jobs of the namespaces of the trackers
+	refreshers map[*MPIOperatorTracker]*refresher
+	// refreshes counts the started refreshes and refreshed is the latest
+	// one whose jobs are observed
+	refreshes uint64
+	refreshed uint64
+	// observed contains the jobs whose queue wait (started) and end
+	// state (finished) is already observed
+	observed map[types.UID]*observedJob
+}
+
+// refresher lists the jobs of the namespace of a tracker.
+type refresher struct {
+	namespace string
+	list      func(ctx context.Context) ([]kubeflow.MPIJob, error)
+}
+
+type observedJob struct {
+	namespace string
+	started   bool
+	startTime time.Time
+	finished  bool
+}
+
+var _ prometheus.Collector = &Metrics{}
+
+// NewMetrics creates the metrics and registers them at the registerer
+// (like prometheus.DefaultRegisterer).
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	jobLabels := []string{"category", "namespace"}
+	m := &Metrics{
+		submitted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "jobs_submitted_total",
+			Help:      "Number of submitted MPI jobs.",
+		}, jobLabels),
+		succeeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "jobs_succeeded_total",
+			Help:      "Number of MPI jobs which finished successfully.",
+		}, jobLabels),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "jobs_failed_total",
+			Help:      "Number of MPI jobs which failed or were terminated.",
+		}, jobLabels),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "job_queue_wait_seconds",
+			Help:      "Time from the creation of an MPI job until it is running.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+		}, jobLabels),
+		runtime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "job_runtime_seconds",
+			Help:      "Time from the start of an MPI job until it finished.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 20),
+		}, jobLabels),
+		running: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "jobs_running",
+			Help:      "Number of running MPI jobs.",
+		}, []string{"namespace"}),
+		slots: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "slots_in_use",
+			Help:      "Number of MPI slots of running jobs.",
+		}, []string{"namespace"}),
+		apiLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "kubernetes_request_duration_seconds",
+			Help:      "Latency of requests to the Kubernetes API server.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"verb", "resource", "code"}),
+		since:      time.Now(),
+		refreshers: make(map[*MPIOperatorTracker]*refresher),
+		observed:   make(map[types.UID]*observedJob),
+	}
+	if err := registerer.Register(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range m.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector. It updates the job metrics
+// before they are collected.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.refresh()
+	for _, collector := range m.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.submitted, m.succeeded, m.failed, m.queueWait,
+		m.runtime, m.running, m.slots, m.apiLatency}
+}
+
+// addRefresher registers the tracker to list the jobs of its namespace
+// when the metrics are collected. Trackers are refreshed separately as
+// trackers of the same namespace can use different clusters.
+func (m *Metrics) addRefresher(t *MPIOperatorTracker) {
+	m.Lock()
+	defer m.Unlock()
+	m.refreshers[t] = &refresher{namespace: t.Namespace(), list: t.listManagedJobs}
+}
+
+// removeRefresher is called when a tracker is closed. The jobs of the
+// namespace are not observed anymore when it was the last tracker of
+// the namespace.
+func (m *Metrics) removeRefresher(t *MPIOperatorTracker) {
+	m.Lock()
+	defer m.Unlock()
+	r, exists := m.refreshers[t]
+	if !exists {
+		return
+	}
+	delete(m.refreshers, t)
+	if m.hasRefresher(r.namespace) {
+		return
+	}
+	for uid, observed := range m.observed {
+		if observed.namespace == r.namespace {
+			delete(m.observed, uid)
+		}
+	}
+	m.running.DeleteLabelValues(r.namespace)
+	m.slots.DeleteLabelValues(r.namespace)
+}
+
+// hasRefresher returns true when a tracker of the namespace is registered.
+func (m *Metrics) hasRefresher(namespace string) bool {
+	for _, r := range m.refreshers {
+		if r.namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh lists the jobs of all trackers and observes them. The jobs are
+// listed without holding the lock so that a slow Kubernetes API server
+// does not block other collections. A namespace is only observed when the
+// jobs of all its trackers could be listed.
+func (m *Metrics) refresh() {
+	m.Lock()
+	m.refreshes++
+	generation := m.refreshes
+	refreshers := make([]*refresher, 0, len(m.refreshers))
+	for _, r := range m.refreshers {
+		refreshers = append(refreshers, r)
+	}
+	m.Unlock()
+
+	jobsByNamespace := make(map[string][]kubeflow.MPIJob)
+	listed := make(map[string]map[types.UID]bool)
+	failed := make(map[string]bool)
+	for _, r := range refreshers {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsRefreshTimeout)
+		jobs, err := r.list(ctx)
+		cancel()
+		if err != nil {
+			// logged by the tracker
+			failed[r.namespace] = true
+			continue
+		}
+		if listed[r.namespace] == nil {
+			listed[r.namespace] = make(map[types.UID]bool, len(jobs))
+			jobsByNamespace[r.namespace] = nil
+		}
+		// trackers of the same cluster see the same jobs
+		for _, job := range jobs {
+			if !listed[r.namespace][job.UID] {
+				listed[r.namespace][job.UID] = true
+				jobsByNamespace[r.namespace] = append(jobsByNamespace[r.namespace], job)
+			}
+		}
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	// a concurrent collection which started later already observed
+	// more recent jobs
+	if generation < m.refreshed {
+		return
+	}
+	m.refreshed = generation
+	now := time.Now()
+	for namespace, jobs := range jobsByNamespace {
+		if failed[namespace] || !m.hasRefresher(namespace) {
+			continue
+		}
+		m.observeJobs(namespace, jobs, now)
+	}
+}
+
+// observeJobs updates the metrics by the current jobs of a namespace.
+func (m *Metrics) observeJobs(namespace string, jobs []kubeflow.MPIJob, now time.Time) {
+	var running, slots int64
+	existing := make(map[types.UID]bool, len(jobs))
+	for i := range jobs {
+		job := &jobs[i]
+		existing[job.UID] = true
+		observed, exists := m.observed[job.UID]
+		if !exists {
+			observed = &observedJob{namespace: namespace}
+			m.observed[job.UID] = observed
+		}
+		category := m.category(job)
+		if startTime, running := runningSince(job); running && !observed.started {
+			observed.started = true
+			observed.startTime = startTime
+			if !startTime.Before(m.since) {
+				m.queueWait.WithLabelValues(category, namespace).Observe(
+					startTime.Sub(job.CreationTimestamp.Time).Seconds())
+			}
+		}
+		state, _, _ := JobStateFromMPIJob(job)
+		switch state {
+		case drmaa2interface.Running:
+			running++
+			if jobSlots := JobInfoFromMPIJob(job).Slots; jobSlots > 0 {
+				slots += jobSlots
+			}
+		case drmaa2interface.Done, drmaa2interface.Failed:
+			finishTime, finished := FinishTime(job)
+			if !finished || observed.finished {
+				continue
+			}
+			observed.finished = true
+			if finishTime.Before(m.since) {
+				continue
+			}
+			if state == drmaa2interface.Done {
+				m.succeeded.WithLabelValues(category, namespace).Inc()
+			} else {
+				m.failed.WithLabelValues(category, namespace).Inc()
+			}
+			// the running condition is false after the job finished,
+			// hence the start time is only known when the job was
+			// observed running
+			startTime := observed.startTime
+			if !observed.started && job.Status.StartTime != nil {
+				startTime = job.Status.StartTime.Time
+			}
+			if !startTime.IsZero() {
+				m.runtime.WithLabelValues(category, namespace).Observe(
+					finishTime.Sub(startTime).Seconds())
+			}
+		}
+	}
+	// forget removed jobs
+	for uid, observed := range m.observed {
+		if observed.namespace == namespace && !existing[uid] {
+			delete(m.observed, uid)
+		}
+	}
+	m.running.WithLabelValues(namespace).Set(float64(running))
+	m.slots.WithLabelValues(namespace).Set(float64(slots))
+}
+
+// jobSubmitted counts a submitted job. It does nothing when the metrics
+// are not enabled (nil).
+func (m *Metrics) jobSubmitted(job *kubeflow.MPIJob) {
+	if m == nil {
+		return
+	}
+	m.submitted.WithLabelValues(m.category(job), job.Namespace).Inc()
+}
+
+// category returns the category label of the job.
+func (m *Metrics) category(job *kubeflow.MPIJob) string {
+	if m.Category != nil {
+		return m.Category(job)
+	}
+	return JobCategory(job)
+}
+
+// WrapTransport records the latency of the requests to the Kubernetes
+// API server. It is set as WrapTransport of the REST config.
+func (m *Metrics) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &latencyRoundTripper{metrics: m, next: rt}
+}
+
+type latencyRoundTripper struct {
+	metrics *Metrics
+	next    http.RoundTripper
+}
+
+func (l *latencyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := l.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	l.metrics.apiLatency.WithLabelValues(req.Method, apiResource(req.URL.Path), code).Observe(
+		time.Since(start).Seconds())
+	return resp, err
+}
+
+// apiResource returns the resource of a Kubernetes API path like
+// /apis/kubeflow.org/v2beta1/namespaces/default/mpijobs/pi (mpijobs).
+func apiResource(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return "other"
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		return "other"
+	}
+	if len(parts) >= 3 {
+		// subresource like pods/log
+		return parts[0] + "/" + parts[2]
+	}
+	return parts[0]
+}
+
+// JobCategory returns the image of the launcher without its tag or
+// digest. The image is the JobCategory of the job template the job was
+// created from.
+func JobCategory(job *kubeflow.MPIJob) string {
+	launcher, exists := job.Spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher]
+	if !exists || launcher == nil || len(launcher.Template.Spec.Containers) == 0 {
+		return ""
+	}
+	return imageRepository(launcher.Template.Spec.Containers[0].Image)
+}
+
+// imageRepository returns the container image without tag and digest.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// runningSince returns the time the job got running. ok is false when
+// the job is not running.
+func runningSince(job *kubeflow.MPIJob) (since time.Time, ok bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == common.JobRunning && condition.Status == corev1.ConditionTrue {
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,170 @@
+package mpioperatortracker
+
+import (
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newFakeObservedJob(name string, created time.Time, conditions ...common.JobCondition) kubeflow.MPIJob {
+	job := newFakeMPIJob(name)
+	job.UID = types.UID(name)
+	job.CreationTimestamp = metav1.NewTime(created)
+	job.Spec.MPIReplicaSpecs = map[kubeflow.MPIReplicaType]*common.ReplicaSpec{
+		kubeflow.MPIReplicaTypeLauncher: {
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Image: "mpi-pi"}},
+				},
+			},
+		},
+	}
+	job.Status.Conditions = conditions
+	return *job
+}
+
+func jobCondition(conditionType common.JobConditionType, status corev1.ConditionStatus, at time.Time) common.JobCondition {
+	return common.JobCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metav1.NewTime(at),
+	}
+}
+
+var _ = Describe("Metrics", func() {
+
+	var metrics *Metrics
+
+	BeforeEach(func() {
+		var err error
+		metrics, err = NewMetrics(prometheus.NewRegistry())
+		Expect(err).To(BeNil())
+	})
+
+	It("should observe queue wait, runtime, and end states once", func() {
+		created := metrics.since.Add(time.Second)
+		started := created.Add(10 * time.Second)
+		running := newFakeObservedJob("running", created,
+			jobCondition(common.JobCreated, corev1.ConditionTrue, created),
+			jobCondition(common.JobRunning, corev1.ConditionTrue, started))
+		metrics.observeJobs("default", []kubeflow.MPIJob{running}, time.Now())
+
+		Expect(testutil.ToFloat64(metrics.running.WithLabelValues("default"))).To(Equal(1.0))
+		Expect(testutil.CollectAndCount(metrics.queueWait)).To(Equal(1))
+
+		finished := started.Add(time.Minute)
+		running.Status.Conditions = []common.JobCondition{
+			jobCondition(common.JobCreated, corev1.ConditionTrue, created),
+			jobCondition(common.JobRunning, corev1.ConditionFalse, finished),
+			jobCondition(common.JobSucceeded, corev1.ConditionTrue, finished),
+		}
+		failed := newFakeObservedJob("failed", created,
+			jobCondition(common.JobFailed, corev1.ConditionTrue, finished))
+		jobs := []kubeflow.MPIJob{running, failed}
+		metrics.observeJobs("default", jobs, time.Now())
+		metrics.observeJobs("default", jobs, time.Now())
+
+		Expect(testutil.ToFloat64(metrics.running.WithLabelValues("default"))).To(Equal(0.0))
+		Expect(testutil.ToFloat64(metrics.succeeded.WithLabelValues("mpi-pi", "default"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.failed.WithLabelValues("mpi-pi", "default"))).To(Equal(1.0))
+		// the failed job was never running
+		Expect(testutil.CollectAndCount(metrics.runtime)).To(Equal(1))
+	})
+
+	It("should not observe jobs which finished before the metrics were created", func() {
+		before := metrics.since.Add(-time.Hour)
+		job := newFakeObservedJob("old", before,
+			jobCondition(common.JobSucceeded, corev1.ConditionTrue, before))
+		metrics.observeJobs("default", []kubeflow.MPIJob{job}, time.Now())
+		Expect(testutil.CollectAndCount(metrics.succeeded)).To(Equal(0))
+	})
+
+	It("should count submitted jobs and list the jobs of the namespace on collection", func() {
+		tracker := &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(),
+			namespace: "mpi-jobs",
+			metrics:   metrics,
+		}
+		metrics.addRefresher(tracker)
+
+		_, err := tracker.AddJob(drmaa2interface.JobTemplate{JobCategory: "mpi-pi", MinSlots: 2})
+		Expect(err).To(BeNil())
+		Expect(testutil.ToFloat64(metrics.submitted.WithLabelValues("mpi-pi", "mpi-jobs"))).To(Equal(1.0))
+
+		Expect(testutil.CollectAndCount(metrics, "mpioperatortracker_jobs_running")).To(Equal(1))
+		Expect(tracker.Close()).To(BeNil())
+		Expect(testutil.CollectAndCount(metrics, "mpioperatortracker_jobs_running")).To(Equal(0))
+	})
+
+	It("should refresh trackers of the same namespace on different clusters separately", func() {
+		newClusterTracker := func(name string) *MPIOperatorTracker {
+			job := newFakeObservedJob(name, time.Now(),
+				jobCondition(common.JobRunning, corev1.ConditionTrue, time.Now()))
+			job.Labels = map[string]string{LabelManagedBy: ManagedByValue}
+			return &MPIOperatorTracker{
+				clientset: fake.NewSimpleClientset(&job),
+				namespace: "default",
+				metrics:   metrics,
+			}
+		}
+		first := newClusterTracker("first")
+		second := newClusterTracker("second")
+		metrics.addRefresher(first)
+		metrics.addRefresher(second)
+
+		metrics.refresh()
+		Expect(testutil.ToFloat64(metrics.running.WithLabelValues("default"))).To(Equal(2.0))
+
+		Expect(first.Close()).To(BeNil())
+		metrics.refresh()
+		Expect(testutil.ToFloat64(metrics.running.WithLabelValues("default"))).To(Equal(1.0))
+		Expect(second.Close()).To(BeNil())
+	})
+
+	It("should label jobs by their launcher image without tag or digest", func() {
+		job := newFakeObservedJob("pi", time.Now())
+		for image, category := range map[string]string{
+			"mpioperator/mpi-pi:openmpi":                   "mpioperator/mpi-pi",
+			"registry.local:5000/mpi-pi":                   "registry.local:5000/mpi-pi",
+			"registry.local:5000/mpi-pi:v1@sha256:abcdef":  "registry.local:5000/mpi-pi",
+			"mpioperator/mpi-pi@sha256:0123456789abcdef01": "mpioperator/mpi-pi",
+		} {
+			job.Spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher].Template.Spec.Containers[0].Image = image
+			Expect(JobCategory(&job)).To(Equal(category))
+		}
+
+		metrics.Category = func(job *kubeflow.MPIJob) string {
+			return job.Labels[LabelManagedBy]
+		}
+		tracker := &MPIOperatorTracker{clientset: fake.NewSimpleClientset(), metrics: metrics}
+		_, err := tracker.AddJob(drmaa2interface.JobTemplate{JobCategory: "mpi-pi:v2", MinSlots: 2})
+		Expect(err).To(BeNil())
+		Expect(testutil.ToFloat64(metrics.submitted.WithLabelValues(ManagedByValue, "default"))).To(Equal(1.0))
+	})
+
+	It("should return the resource of Kubernetes API paths", func() {
+		Expect(apiResource("/apis/kubeflow.org/v2beta1/namespaces/default/mpijobs/pi")).To(Equal("mpijobs"))
+		Expect(apiResource("/api/v1/namespaces/default/pods/pi-launcher/log")).To(Equal("pods/log"))
+		Expect(apiResource("/api/v1/nodes")).To(Equal("nodes"))
+		Expect(apiResource("/version")).To(Equal("other"))
+	})
+
+	It("should fail to register the metrics twice", func() {
+		registry := prometheus.NewRegistry()
+		_, err := NewMetrics(registry)
+		Expect(err).To(BeNil())
+		_, err = NewMetrics(registry)
+		Expect(err).NotTo(BeNil())
+	})
+
+})
@@ -0,0 +1,212 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/d2hlp"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MPIOperatorTracker implements the Monitorer interface on top of the
+// JobTracker interface so that MonitoringSessions can be created by
+// the SessionManager. Jobs are listed across all namespaces, hence job
+// IDs of the monitoring session have the form namespace/name. Queues are
+// the scheduler queues of the MPIJobs (SchedulingPolicy.Queue, set by the
+// QueueName of the job template and reported as QueueName of the job
+// info) and machines are Kubernetes nodes.
+
+// ResourceNvidiaGPU is the extended resource name of NVIDIA GPUs.
+const ResourceNvidiaGPU v1.ResourceName = "nvidia.com/gpu"
+
+// ExtensionMachineGPUs is the key in the extension list of a machine
+// which contains the amount of GPUs of a node.
+const ExtensionMachineGPUs = "gpus"
+
+var _ jobtracker.Monitorer = &MPIOperatorTracker{}
+
+func (t *MPIOperatorTracker) OpenMonitoringSession(name string) error {
+	return nil
+}
+
+func (t *MPIOperatorTracker) CloseMonitoringSession(name string) error {
+	return nil
+}
+
+// GetAllJobIDs returns the IDs (namespace/name) of all MPIJobs in all
+// namespaces which match the filter. The filter must be created with
+// drmaa2interface.CreateJobInfo(); nil returns all jobs.
+func (t *MPIOperatorTracker) GetAllJobIDs(filter *drmaa2interface.JobInfo) ([]string, error) {
+	var ids []string
+	continueToken := ""
+	for {
+		jobs, err := ListJobsWithOptions(context.Background(), t.clientset, metav1.NamespaceAll,
+			metav1.ListOptions{Continue: continueToken, Limit: 500})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list MPI jobs of all namespaces: %v", err)
+		}
+		for i := range jobs.Items {
+			id := jobs.Items[i].Namespace + "/" + jobs.Items[i].Name
+			if filter != nil {
+				jobInfo := JobInfoFromMPIJob(&jobs.Items[i])
+				jobInfo.ID = id
+				if !d2hlp.JobInfoMatches(jobInfo, *filter) {
+					continue
+				}
+			}
+			ids = append(ids, id)
+		}
+		if jobs.Continue == "" {
+			return ids, nil
+		}
+		continueToken = jobs.Continue
+	}
+}
+
+// GetAllQueueNames returns the scheduler queues (SchedulingPolicy.Queue)
+// of the MPIJobs in all namespaces, like the queues of a gang scheduler.
+// The queue objects themselves depend on the scheduler, hence queues
+// without MPIJobs are not listed. If filter is != nil it returns only
+// queues which are defined by the filter.
+func (t *MPIOperatorTracker) GetAllQueueNames(filter []string) ([]string, error) {
+	queues := []string{}
+	continueToken := ""
+	for {
+		jobs, err := ListJobsWithOptions(context.Background(), t.clientset, metav1.NamespaceAll,
+			metav1.ListOptions{Continue: continueToken, Limit: 500})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list MPI jobs of all namespaces: %v", err)
+		}
+		for i := range jobs.Items {
+			queue := JobInfoFromMPIJob(&jobs.Items[i]).QueueName
+			if queue == "" || contains(queues, queue) {
+				continue
+			}
+			if filter != nil && !contains(filter, queue) {
+				continue
+			}
+			queues = append(queues, queue)
+		}
+		if jobs.Continue == "" {
+			break
+		}
+		continueToken = jobs.Continue
+	}
+	sort.Strings(queues)
+	return queues, nil
+}
+
+// GetAllMachines returns all Kubernetes nodes as machines. If filter is
+// != nil only nodes with the given names are returned. The amount of
+// GPUs of a node is stored in the ExtensionMachineGPUs extension.
+func (t *MPIOperatorTracker) GetAllMachines(filter []string) ([]drmaa2interface.Machine, error) {
+	if t.kubeClient == nil {
+		return nil, fmt.Errorf("kubernetes client is not set")
+	}
+	nodeList, err := t.kubeClient.CoreV1().Nodes().List(context.Background(),
+		metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubernetes node list: %v", err)
+	}
+	machines := make([]drmaa2interface.Machine, 0, len(nodeList.Items))
+	for i := range nodeList.Items {
+		if filter != nil && !contains(filter, nodeList.Items[i].Name) {
+			continue
+		}
+		machines = append(machines, MachineFromNode(&nodeList.Items[i]))
+	}
+	return machines, nil
+}
+
+// JobInfoFromMonitor returns the job info of a job with an ID in the form
+// namespace/name. IDs without namespace refer to the namespace of the
+// tracker.
+func (t *MPIOperatorTracker) JobInfoFromMonitor(id string) (drmaa2interface.JobInfo, error) {
+	namespace, name := t.Namespace(), id
+	if parts := strings.SplitN(id, "/", 2); len(parts) == 2 {
+		namespace, name = parts[0], parts[1]
+	}
+	jobInfo, err := GetJobInfo(context.Background(), t.clientset, namespace, name)
+	if err != nil {
+		return drmaa2interface.JobInfo{}, err
+	}
+	jobInfo.ID = id
+	return jobInfo, nil
+}
+
+// MachineFromNode converts a Kubernetes node into a DRMAA2 machine.
+func MachineFromNode(node *v1.Node) drmaa2interface.Machine {
+	mem, _ := node.Status.Capacity.Memory().AsInt64()
+	cores, _ := node.Status.Capacity.Cpu().AsInt64()
+
+	var os drmaa2interface.OS
+	switch node.Status.NodeInfo.OperatingSystem {
+	case "linux":
+		os = drmaa2interface.Linux
+	case "darwin":
+		os = drmaa2interface.MacOS
+	case "windows":
+		os = drmaa2interface.Win
+	}
+
+	var arch drmaa2interface.CPU
+	switch {
+	case strings.HasPrefix(node.Status.NodeInfo.Architecture, "ppc64"):
+		arch = drmaa2interface.PowerPC64
+	case node.Status.NodeInfo.Architecture == "amd64":
+		arch = drmaa2interface.X64
+	case strings.HasPrefix(node.Status.NodeInfo.Architecture, "arm64"):
+		arch = drmaa2interface.ARM64
+	}
+
+	osver := append(strings.Split(node.Status.NodeInfo.KernelVersion, "."), "0", "0", "0")
+
+	machine := drmaa2interface.Machine{
+		Name:           node.Name,
+		Available:      isNodeAvailable(node),
+		PhysicalMemory: mem,
+		VirtualMemory:  mem,
+		OS:             os,
+		Architecture:   arch,
+		OSVersion: drmaa2interface.Version{
+			Major: osver[0],
+			Minor: osver[1] + "." + osver[2],
+		},
+		Sockets:        1, // don't know better
+		CoresPerSocket: cores,
+		ThreadsPerCore: 1, // don't know better
+	}
+	if gpus, exists := node.Status.Capacity[ResourceNvidiaGPU]; exists {
+		machine.ExtensionList = map[string]string{
+			ExtensionMachineGPUs: gpus.String(),
+		}
+	}
+	return machine
+}
+
+// isNodeAvailable returns true if the node is ready and schedulable.
+func isNodeAvailable(node *v1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
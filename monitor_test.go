@@ -0,0 +1,112 @@
+package mpioperatortracker
+
+import (
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Monitorer", func() {
+
+	var tracker *MPIOperatorTracker
+
+	BeforeEach(func() {
+		otherJob := newFakeMPIJob("other", common.JobCreated, common.JobRunning, common.JobSucceeded)
+		otherJob.Namespace = "team"
+		otherJob.Spec.RunPolicy.SchedulingPolicy = &common.SchedulingPolicy{Queue: "gpu"}
+		runningJob := newFakeMPIJob("running", common.JobCreated, common.JobRunning)
+		runningJob.Spec.RunPolicy.SchedulingPolicy = &common.SchedulingPolicy{Queue: "default"}
+		gpuNode := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gpu-node"},
+			Status: corev1.NodeStatus{
+				Capacity: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("16"),
+					corev1.ResourceMemory: resource.MustParse("64Gi"),
+					ResourceNvidiaGPU:     resource.MustParse("4"),
+				},
+				Conditions: []corev1.NodeCondition{
+					{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				},
+				NodeInfo: corev1.NodeSystemInfo{
+					OperatingSystem: "linux",
+					Architecture:    "amd64",
+					KernelVersion:   "5.15.0-1019-aws",
+				},
+			},
+		}
+		cpuNode := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "cpu-node"},
+			Spec:       corev1.NodeSpec{Unschedulable: true},
+		}
+		tracker = &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(
+				runningJob,
+				otherJob,
+			),
+			kubeClient: k8sfake.NewSimpleClientset(
+				gpuNode,
+				cpuNode,
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+			),
+		}
+	})
+
+	It("should list MPI jobs of all namespaces", func() {
+		ids, err := tracker.GetAllJobIDs(nil)
+		Expect(err).To(BeNil())
+		Expect(ids).To(ConsistOf("default/running", "team/other"))
+
+		filter := drmaa2interface.CreateJobInfo()
+		filter.State = drmaa2interface.Done
+		ids, err = tracker.GetAllJobIDs(&filter)
+		Expect(err).To(BeNil())
+		Expect(ids).To(ConsistOf("team/other"))
+
+		jobInfo, err := tracker.JobInfoFromMonitor("team/other")
+		Expect(err).To(BeNil())
+		Expect(jobInfo.ID).To(Equal("team/other"))
+		Expect(jobInfo.State).To(Equal(drmaa2interface.Done))
+	})
+
+	It("should list the scheduler queues of the jobs as queues", func() {
+		queues, err := tracker.GetAllQueueNames(nil)
+		Expect(err).To(BeNil())
+		Expect(queues).To(Equal([]string{"default", "gpu"}))
+		queues, err = tracker.GetAllQueueNames([]string{"gpu", "unknown"})
+		Expect(err).To(BeNil())
+		Expect(queues).To(Equal([]string{"gpu"}))
+
+		jobInfo, err := tracker.JobInfoFromMonitor("team/other")
+		Expect(err).To(BeNil())
+		Expect(jobInfo.QueueName).To(Equal("gpu"))
+	})
+
+	It("should list nodes as machines", func() {
+		machines, err := tracker.GetAllMachines(nil)
+		Expect(err).To(BeNil())
+		Expect(machines).To(HaveLen(2))
+
+		machines, err = tracker.GetAllMachines([]string{"gpu-node"})
+		Expect(err).To(BeNil())
+		Expect(machines).To(HaveLen(1))
+		Expect(machines[0].Name).To(Equal("gpu-node"))
+		Expect(machines[0].Available).To(BeTrue())
+		Expect(machines[0].CoresPerSocket).To(BeNumerically("==", 16))
+		Expect(machines[0].PhysicalMemory).To(BeNumerically("==", 64*1024*1024*1024))
+		Expect(machines[0].OS).To(Equal(drmaa2interface.Linux))
+		Expect(machines[0].Architecture).To(Equal(drmaa2interface.X64))
+		Expect(machines[0].ExtensionList[ExtensionMachineGPUs]).To(Equal("4"))
+
+		machines, err = tracker.GetAllMachines([]string{"cpu-node"})
+		Expect(err).To(BeNil())
+		Expect(machines[0].Available).To(BeFalse())
+	})
+
+})
@@ -2,22 +2,135 @@ package mpioperatortracker
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/d2hlp"
 	"github.com/dgruber/drmaa2os/pkg/helper"
 	"github.com/dgruber/drmaa2os/pkg/jobtracker"
+	"github.com/go-logr/logr"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
 	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/transport"
 )
 
 type MPIOperatorTracker struct {
-	clientset clientset.Interface
+	clientset  clientset.Interface
+	kubeClient kubernetes.Interface
+
+	// namespace in which jobs are submitted; empty is "default"
+	namespace string
+	// jobSessionName is added as label to all jobs; ListJobs only
+	// returns jobs of the job session if set
+	jobSessionName string
+	// defaultTemplate contains values used for unset job template fields
+	defaultTemplate drmaa2interface.JobTemplate
+	// store records submitted jobs; nil if not configured
+	store *JobStore
+	// defaultTTL is the TTLSecondsAfterFinished of jobs which do not
+	// set it through the job template; nil keeps finished jobs
+	defaultTTL *int32
+	// nodeFitCheck defines if worker requests are checked against the
+	// nodes of the cluster at submission time
+	nodeFitCheck NodeFitCheck
+	// capabilities of the MPI operator detected at creation
+	capabilities Capabilities
+	// apiVersion is the MPIJob API version of the clientset
+	apiVersion string
+	// metrics are updated by the tracker; nil if not configured
+	metrics *Metrics
+	// logger receives the logs of the tracker; logs are discarded if
+	// not set
+	logger logr.Logger
+
+	// interval for checking finished tasks of array jobs with maxParallel
+	arrayPollInterval time.Duration
+	// arrayJobsMu protects arraySubmissions
+	arrayJobsMu sync.Mutex
+	// arraySubmissions are the array jobs with tasks submitted in the
+	// background by this tracker
+	arraySubmissions map[string]*arrayJobSubmission
+
+	// ctx is cancelled by Close, which stops the background goroutines
+	// tracked by background; created by backgroundContext
+	ctx        context.Context
+	cancel     context.CancelFunc
+	initOnce   sync.Once
+	background sync.WaitGroup
+}
+
+// MPIOperatorTrackerParams are the parameters for creating a new
+// MPIOperatorTracker. It is also the type of the tracker parameters
+// which can be passed to the drmaa2os SessionManager.
+type MPIOperatorTrackerParams struct {
+	// Namespace in which the MPIJobs are created. Default is "default".
+	Namespace string
+	// KubeconfigPath is the path to the kubeconfig file. If not set
+	// KUBECONFIG or $HOME/.kube/config is used.
+	KubeconfigPath string
+	// JobSessionName is set as label on all created jobs. ListJobs
+	// only returns jobs of the same job session if set.
+	JobSessionName string
+	// DefaultTemplate contains default values which are used when the
+	// corresponding fields of a submitted job template are not set
+	// (JobCategory, MinSlots, MaxSlots, QueueName, WorkingDirectory,
+	// and entries of JobEnvironment, ExtensionList, and StageInFiles).
+	DefaultTemplate drmaa2interface.JobTemplate
+	// JobStorePath is the path to a local database which records all
+	// submitted jobs, so that job sessions can be reopened and finished
+	// jobs are still queryable after the MPIJob is removed. Not set
+	// means no local database is used.
+	JobStorePath string
+	// DefaultTTLSecondsAfterFinished is the TTLSecondsAfterFinished of
+	// jobs whose job template does not set the ttlSecondsAfterFinished
+	// extension. The operator removes the launcher Job after the TTL but
+	// keeps the MPIJob (see Reap). nil keeps the launcher Job.
+	DefaultTTLSecondsAfterFinished *int32
+	// NodeFitCheck defines if a warning is logged (NodeFitCheckWarn) or
+	// the job is rejected (NodeFitCheckError) when the resource requests
+	// of a worker don't fit on any node. Default is no check.
+	NodeFitCheck NodeFitCheck
+	// APIVersion is the MPIJob API version (APIVersionV2beta1 or
+	// APIVersionV1) the jobs are submitted to. When not set it is
+	// detected: v2beta1 is preferred over v1. Without operator check
+	// v2beta1 is used.
+	APIVersion string
+	// Metrics records the jobs of the namespace and the latency of the
+	// requests to the Kubernetes API server (see NewMetrics). The same
+	// metrics can be used by multiple trackers. nil disables metrics.
+	Metrics *Metrics
+	// Logger receives the structured logs of the tracker with the fields
+	// jobID, namespace, and operation. Verbosity levels are
+	// LogLevelOperations and LogLevelDebug. Not set discards the logs.
+	Logger logr.Logger
+	// SkipOperatorCheck does not check at creation of the tracker that
+	// the MPI operator is installed. Capabilities() is empty then.
+	SkipOperatorCheck bool
+	// TestInstallMPIOperator installs the MPI operator when creating
+	// the tracker. Only for testing.
+	TestInstallMPIOperator bool
 }
 
 func NewMPIOperatorTracker(kubeconfigPath string, testInstallMPIOperator bool) (*MPIOperatorTracker, error) {
+	return NewMPIOperatorTrackerWithParams(MPIOperatorTrackerParams{
+		KubeconfigPath:         kubeconfigPath,
+		TestInstallMPIOperator: testInstallMPIOperator,
+	})
+}
+
+// NewMPIOperatorTrackerWithParams creates a new MPIOperatorTracker
+// configured by the given parameters.
+func NewMPIOperatorTrackerWithParams(params MPIOperatorTrackerParams) (*MPIOperatorTracker, error) {
+	kubeconfigPath := params.KubeconfigPath
 	if kubeconfigPath == "" {
 		kubeconfigPath = os.Getenv("KUBECONFIG")
 		if kubeconfigPath == "" {
@@ -26,7 +139,7 @@ func NewMPIOperatorTracker(kubeconfigPath string, testInstallMPIOperator bool) (
 	}
 
 	// only for testing: Installs MPIOperator itself
-	if testInstallMPIOperator {
+	if params.TestInstallMPIOperator {
 		err := InstallMPIOperator(kubeconfigPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to install MPIOperator: %v\n", err)
@@ -37,46 +150,314 @@ func NewMPIOperatorTracker(kubeconfigPath string, testInstallMPIOperator bool) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to create REST config: %v\n", err)
 	}
-	cs, err := GetClient(restConfig)
+	if params.Metrics != nil {
+		restConfig.WrapTransport = transport.Wrappers(restConfig.WrapTransport,
+			params.Metrics.WrapTransport)
+	}
+	kubeClient, err := GetKubernetesClient(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %v\n", err)
+	}
+	var capabilities Capabilities
+	if !params.SkipOperatorCheck {
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+		}
+		capabilities, err = DetectCapabilities(context.Background(), kubeClient, dynamicClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect MPI operator: %v", err)
+		}
+	}
+	apiVersion := params.APIVersion
+	if apiVersion == "" {
+		apiVersion = capabilities.PreferredAPIVersion()
+	} else if !params.SkipOperatorCheck && !capabilities.SupportsAPIVersion(apiVersion) {
+		return nil, fmt.Errorf("MPIJob API version %s is not served (served versions: %v)",
+			apiVersion, capabilities.APIVersions)
+	}
+	cs, err := GetClientForAPIVersion(restConfig, apiVersion)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create client: %v\n", err)
+		return nil, fmt.Errorf("failed to create client: %v", err)
 	}
-	return &MPIOperatorTracker{
-		clientset: cs,
-	}, nil
+	var store *JobStore
+	if params.JobStorePath != "" {
+		store, err = OpenJobStore(params.JobStorePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open job store: %v", err)
+		}
+	}
+	t := &MPIOperatorTracker{
+		clientset:       cs,
+		kubeClient:      kubeClient,
+		namespace:       params.Namespace,
+		jobSessionName:  params.JobSessionName,
+		defaultTemplate: params.DefaultTemplate,
+		store:           store,
+		defaultTTL:      params.DefaultTTLSecondsAfterFinished,
+		nodeFitCheck:    params.NodeFitCheck,
+		capabilities:    capabilities,
+		apiVersion:      apiVersion,
+		metrics:         params.Metrics,
+		logger:          params.Logger,
+	}
+	if t.metrics != nil {
+		t.metrics.addRefresher(t)
+	}
+	return t, nil
+}
+
+// listManagedJobs returns all jobs of the namespace which are created by
+// a tracker, independent of the job session.
+func (t *MPIOperatorTracker) listManagedJobs(ctx context.Context) ([]kubeflow.MPIJob, error) {
+	jobs, err := ListJobsWithOptions(ctx, t.clientset, t.Namespace(),
+		metav1.ListOptions{LabelSelector: LabelManagedBy + "=" + ManagedByValue})
+	if err != nil {
+		t.jobLogger("", "metrics").Error(err, "failed to list jobs for metrics")
+		return nil, err
+	}
+	return jobs.Items, nil
+}
+
+// backgroundContext returns the context of background operations of the
+// tracker which is cancelled by Close.
+func (t *MPIOperatorTracker) backgroundContext() context.Context {
+	t.initOnce.Do(func() {
+		t.ctx, t.cancel = context.WithCancel(context.Background())
+	})
+	return t.ctx
+}
+
+// Namespace returns the namespace in which the tracker manages jobs.
+func (t *MPIOperatorTracker) Namespace() string {
+	if t.namespace == "" {
+		return "default"
+	}
+	return t.namespace
 }
 
-// ListJobs returns all visible job IDs or an error.
+// newMPIJob creates an MPIJob in the namespace of the tracker which is
+// labeled with the owner and the job session.
+func (t *MPIOperatorTracker) newMPIJob(spec kubeflow.MPIJobSpec) kubeflow.MPIJob {
+	job := NewMPIJob(spec)
+	t.prepareMPIJob(&job)
+	return job
+}
+
+func (t *MPIOperatorTracker) prepareMPIJob(job *kubeflow.MPIJob) {
+	job.Namespace = t.Namespace()
+	SetJobOwner(job, CurrentJobOwner())
+	if t.jobSessionName != "" {
+		job.Labels[LabelJobSession] = toLabelValue(t.jobSessionName)
+	}
+	if job.Spec.RunPolicy.TTLSecondsAfterFinished == nil && t.defaultTTL != nil {
+		job.Spec.RunPolicy.TTLSecondsAfterFinished = toInt32(*t.defaultTTL)
+	}
+}
+
+// ListJobs returns all visible job IDs or an error. Only jobs which are
+// created by the tracker are visible. With a job store, jobs which were
+// already removed from the cluster are part of the list as well.
 func (t *MPIOperatorTracker) ListJobs() ([]string, error) {
-	jobs, err := ListJobs(context.Background(), t.clientset, "default")
+	var names []string
+	continueToken := ""
+	for {
+		page, next, err := t.ListJobsFiltered(ListJobsFilter{Continue: continueToken})
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, page...)
+		if next == "" {
+			break
+		}
+		continueToken = next
+	}
+	if t.store == nil {
+		return names, nil
+	}
+	records, err := t.store.List(t.Namespace(), t.jobSessionName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list MPIOperator jobs: %v\n", err)
+		return nil, fmt.Errorf("failed to list jobs of job store: %v", err)
 	}
-	names := make([]string, len(jobs))
-	for job := range jobs {
-		names = append(names, jobs[job].Name)
+	for _, record := range records {
+		if !contains(names, record.JobID) {
+			names = append(names, record.JobID)
+		}
 	}
 	return names, nil
 }
 
+// ListJobsFilter selects the jobs returned by ListJobsFiltered.
+type ListJobsFilter struct {
+	// JobInfo filters jobs by their job info (like state, owner, queue
+	// name, or submission time). It needs to be created with
+	// drmaa2interface.CreateJobInfo() so that unset fields are ignored.
+	// nil does not filter any job.
+	JobInfo *drmaa2interface.JobInfo
+	// LabelSelector is an additional Kubernetes label selector.
+	LabelSelector string
+	// AllJobs includes jobs which are not created by the tracker or
+	// which belong to other job sessions.
+	AllJobs bool
+	// Limit is the max. amount of jobs requested from the cluster for
+	// one page. 0 means no limit.
+	Limit int64
+	// Continue is the token returned by a previous call for getting the
+	// next page.
+	Continue string
+}
+
+// ListJobsFiltered returns one page of job IDs matching the filter and the
+// continue token for the next page. The continue token is empty when there
+// are no more pages. As the JobInfo filter is applied after a page has been
+// fetched, a page can contain less than Limit jobs.
+func (t *MPIOperatorTracker) ListJobsFiltered(filter ListJobsFilter) ([]string, string, error) {
+	jobInfos, next, err := t.ListJobInfosFiltered(filter)
+	if err != nil {
+		return nil, "", err
+	}
+	names := make([]string, 0, len(jobInfos))
+	for _, jobInfo := range jobInfos {
+		names = append(names, jobInfo.ID)
+	}
+	return names, next, nil
+}
+
+// ListJobInfosFiltered is like ListJobsFiltered but returns the job infos
+// of the jobs. They are derived from one list request, so that no
+// request per job is required.
+func (t *MPIOperatorTracker) ListJobInfosFiltered(filter ListJobsFilter) ([]drmaa2interface.JobInfo, string, error) {
+	selector, err := t.jobSelector(filter.LabelSelector, filter.AllJobs)
+	if err != nil {
+		return nil, "", err
+	}
+	if filter.JobInfo != nil && filter.JobInfo.JobOwner != "" {
+		// the owner label narrows the list, the job info filter compares
+		// the unmodified owner name
+		owner, err := labels.NewRequirement(LabelJobOwner, selection.Equals,
+			[]string{toLabelValue(filter.JobInfo.JobOwner)})
+		if err != nil {
+			return nil, "", newError(drmaa2interface.InvalidArgument, "invalid job owner filter: %v", err)
+		}
+		selector = selector.Add(*owner)
+	}
+	jobs, err := ListJobsWithOptions(context.Background(), t.clientset, t.Namespace(),
+		metav1.ListOptions{
+			LabelSelector: selector.String(),
+			Limit:         filter.Limit,
+			Continue:      filter.Continue,
+		})
+	if err != nil {
+		return nil, "", wrapError(err, "failed to list MPIOperator jobs")
+	}
+	jobInfos := make([]drmaa2interface.JobInfo, 0, len(jobs.Items))
+	for i := range jobs.Items {
+		jobInfo := JobInfoFromMPIJob(&jobs.Items[i])
+		t.recordJobInfo(jobInfo)
+		if filter.JobInfo != nil && !d2hlp.JobInfoMatches(jobInfo, *filter.JobInfo) {
+			continue
+		}
+		jobInfos = append(jobInfos, jobInfo)
+	}
+	return jobInfos, jobs.Continue, nil
+}
+
+// jobSelector returns the label selector for jobs created by the tracker
+// in its job session, combined with an additional label selector. When
+// allJobs is set only the additional label selector is used.
+func (t *MPIOperatorTracker) jobSelector(labelSelector string, allJobs bool) (labels.Selector, error) {
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, newError(drmaa2interface.InvalidArgument, "invalid label selector: %v", err)
+	}
+	if allJobs {
+		return selector, nil
+	}
+	managedBy, err := labels.NewRequirement(LabelManagedBy, selection.Equals, []string{ManagedByValue})
+	if err != nil {
+		return nil, err
+	}
+	selector = selector.Add(*managedBy)
+	if t.jobSessionName != "" {
+		session, err := labels.NewRequirement(LabelJobSession, selection.Equals,
+			[]string{toLabelValue(t.jobSessionName)})
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*session)
+	}
+	return selector, nil
+}
+
 // ListArrayJobs returns all job IDs an job array ID (or array job ID)
 // represents or an error.
 func (t *MPIOperatorTracker) ListArrayJobs(arrayjobID string) ([]string, error) {
-	return helper.ArrayJobID2GUIDs(arrayjobID)
+	tasks, err := t.ListArrayJobTasks(arrayjobID)
+	if err != nil {
+		return nil, err
+	}
+	jobIDs := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		jobIDs = append(jobIDs, task.JobID)
+	}
+	return jobIDs, nil
+}
+
+// ListArrayJobTasks returns the tasks of an array job found in the cluster
+// together with their states, sorted by task ID. Tasks which were already
+// deleted are not part of the result.
+func (t *MPIOperatorTracker) ListArrayJobTasks(arrayjobID string) ([]ArrayTask, error) {
+	jobs, err := ListArrayJobTasks(context.Background(), t.clientset, t.Namespace(), arrayjobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks of array job %s: %v", arrayjobID, err)
+	}
+	if len(jobs) == 0 {
+		// tasks might be removed from the cluster already
+		tasks, err := t.storedArrayTasks(arrayjobID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tasks of array job %s: %v", arrayjobID, err)
+		}
+		if len(tasks) == 0 {
+			return nil, newError(drmaa2interface.InvalidArgument, "array job %s not found", arrayjobID)
+		}
+		return tasks, nil
+	}
+	return ArrayTasksFromMPIJobs(jobs), nil
 }
 
 // AddJob typically submits or starts a new job at the backend. The function
 // returns the unique job ID or an error if job submission (or starting of
 // the job in case there is no queueing system) has failed.
 func (t *MPIOperatorTracker) AddJob(jobTemplate drmaa2interface.JobTemplate) (string, error) {
+	jobTemplate = MergeJobTemplateDefaults(jobTemplate, t.defaultTemplate)
 	spec, err := ConvertJobTemplateToMPIJob(jobTemplate)
 	if err != nil {
-		return "", fmt.Errorf("failed to convert DRMAA2 job template to MPI job: %v\n", err)
+		return "", newError(drmaa2interface.InvalidArgument, "failed to convert DRMAA2 job template to MPI job: %v", err)
+	}
+	if err := t.checkNodeFit(spec); err != nil {
+		return "", err
+	}
+	job := t.newMPIJob(spec)
+	if err := ValidateMPIJob(&job, t.APIVersion()); err != nil {
+		return "", newError(drmaa2interface.InvalidArgument, "invalid MPI job: %v", err)
 	}
-	job := NewMPIJob(spec)
 	jobID, err := CreateJob(context.TODO(), t.clientset, &job, false)
 	if err != nil {
-		return "", fmt.Errorf("failed to create job: %v\n", err)
+		return "", wrapError(err, "failed to create job")
+	}
+	t.metrics.jobSubmitted(&job)
+	logger := t.jobLogger(jobID.Name, "submit")
+	logger.V(LogLevelOperations).Info("submitted job", "jobCategory", jobTemplate.JobCategory)
+	for _, warning := range JobTemplateWarnings(jobTemplate) {
+		logger.Info("ignored job template setting", "reason", warning)
+	}
+	if GetElasticExtension(jobTemplate) && t.capabilities.OperatorImage != "" &&
+		!t.capabilities.MinAvailableSupported() {
+		logger.Info("MPI operator gang schedules elastic jobs with all workers",
+			"operatorVersion", t.capabilities.OperatorVersion)
+	}
+	if err := t.recordJob(jobID.Name, jobTemplate, "", 0); err != nil {
+		return jobID.Name, err
 	}
 	return jobID.Name, nil
 }
@@ -92,18 +473,113 @@ func (t *MPIOperatorTracker) AddJob(jobTemplate drmaa2interface.JobTemplate) (st
 // Note, that jobs use the TASK_ID environment variable to identifiy which
 // task they are and determine that way what to do (like which data set is
 // accessed).
+//
+// Each task is a separate MPIJob labeled with the array job ID and the
+// task ID. Besides TASK_ID the variables TASK_FIRST, TASK_LAST, and
+// TASK_STEPSIZE are set in the launcher and worker containers. When
+// maxParallel is set, the remaining tasks are submitted in the background
+// as earlier tasks finish, until all are submitted, a submission fails,
+// or the tracker is closed. PendingArrayTasks returns the tasks which are
+// not yet submitted and the error which stopped the submission. With a
+// job store the pending tasks are recorded, so that ResumeArrayJobs can
+// continue the submission after a restart.
+//
+// When submitting one of the first maxParallel tasks fails, the array job
+// ID is returned with the error. The tasks submitted before are not
+// removed and the remaining ones are pending.
 func (t *MPIOperatorTracker) AddArrayJob(jt drmaa2interface.JobTemplate, begin int, end int, step int, maxParallel int) (string, error) {
-	return helper.AddArrayJobAsSingleJobs(jt, t, begin, end, step)
+	taskIDs, err := ArrayTaskIDs(begin, end, step)
+	if err != nil {
+		return "", newError(drmaa2interface.InvalidArgument, "invalid array job definition: %v", err)
+	}
+	jt = MergeJobTemplateDefaults(jt, t.defaultTemplate)
+	// fail early in case the job template is not valid
+	spec, err := ConvertJobTemplateToMPIJob(jt)
+	if err != nil {
+		return "", newError(drmaa2interface.InvalidArgument, "failed to convert DRMAA2 job template to MPI job: %v", err)
+	}
+	job := t.newMPIJob(spec)
+	if err := ValidateMPIJob(&job, t.APIVersion()); err != nil {
+		return "", newError(drmaa2interface.InvalidArgument, "invalid MPI job: %v", err)
+	}
+	if err := t.checkNodeFit(spec); err != nil {
+		return "", err
+	}
+
+	arrayJobID := NewArrayJobID()
+	logger := t.jobLogger(arrayJobID, "submit")
+	logger.V(LogLevelOperations).Info("submitting array job", "jobCategory", jt.JobCategory,
+		"tasks", len(taskIDs), "maxParallel", maxParallel)
+	for _, warning := range JobTemplateWarnings(jt) {
+		logger.Info("ignored job template setting", "reason", warning)
+	}
+
+	if maxParallel <= 0 || maxParallel > len(taskIDs) {
+		maxParallel = len(taskIDs)
+	}
+	record := ArrayJobRecord{
+		ArrayJobID:     arrayJobID,
+		Namespace:      t.Namespace(),
+		JobSessionName: t.jobSessionName,
+		JobTemplate:    jt,
+		Begin:          begin,
+		End:            end,
+		Step:           step,
+		MaxParallel:    maxParallel,
+	}
+	for i, taskID := range taskIDs[:maxParallel] {
+		err := t.addArrayTask(jt, arrayJobID, taskID, begin, end, step)
+		if err != nil {
+			record.PendingTaskIDs = taskIDs[i:]
+			t.setArrayJobSubmission(record, err)
+			return arrayJobID, err
+		}
+	}
+	if remaining := taskIDs[maxParallel:]; len(remaining) > 0 {
+		record.PendingTaskIDs = remaining
+		t.submitInBackground(record)
+	}
+	return arrayJobID, nil
 }
 
 // JobState returns the DRMAA2 state and substate (free form string) of the job.
 func (t *MPIOperatorTracker) JobState(jobID string) (drmaa2interface.JobState, string, error) {
-	return GetJobState(context.Background(), t.clientset, "default", jobID)
+	if t.store == nil {
+		state, subState, err := GetJobState(context.Background(), t.clientset, t.Namespace(), jobID)
+		if apierrors.IsNotFound(err) {
+			return state, subState, &JobNotFoundError{JobID: jobID}
+		}
+		if err != nil {
+			return state, subState, wrapError(err, "failed to get state of job %s", jobID)
+		}
+		return state, subState, nil
+	}
+	jobInfo, err := t.JobInfo(jobID)
+	if err != nil {
+		return drmaa2interface.Undetermined, "unknown job", err
+	}
+	return jobInfo.State, jobInfo.SubState, nil
 }
 
 // JobInfo returns the job status of a job in form of a JobInfo struct or an error.
+// With a job store the job info of finished jobs is recorded whenever the
+// tracker sees them (by JobState, Wait, JobInfo, ListJobsFiltered, or Reap)
+// so that it is available after the MPIJob has been removed from the
+// cluster. Jobs of the store which were removed before their end state was
+// seen are reported as Undetermined with sub state SubStateRemoved.
 func (t *MPIOperatorTracker) JobInfo(jobID string) (drmaa2interface.JobInfo, error) {
-	return GetJobInfo(context.Background(), t.clientset, "default", jobID)
+	jobInfo, err := GetJobInfo(context.Background(), t.clientset, t.Namespace(), jobID)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			if stored, exists := t.storedJobInfo(jobID); exists {
+				return stored, nil
+			}
+			return drmaa2interface.JobInfo{}, &JobNotFoundError{JobID: jobID}
+		}
+		return drmaa2interface.JobInfo{}, wrapError(err, "failed to get job info of job %s", jobID)
+	}
+	t.recordJobInfo(jobInfo)
+	return jobInfo, nil
 }
 
 // JobControl sends a request to the backend to either "terminate", "suspend",
@@ -114,26 +590,50 @@ func (t *MPIOperatorTracker) JobInfo(jobID string) (drmaa2interface.JobInfo, err
 func (t *MPIOperatorTracker) JobControl(jobID string, action string) error {
 	switch action {
 	case jobtracker.JobControlSuspend:
-		return errors.New("unsupported operation")
+		return newError(drmaa2interface.UnsupportedOperation, "unsupported operation")
 	case jobtracker.JobControlResume:
-		return errors.New("unsupported operation")
+		return newError(drmaa2interface.UnsupportedOperation, "unsupported operation")
 	case jobtracker.JobControlHold:
-		return errors.New("unsupported operation")
+		return newError(drmaa2interface.UnsupportedOperation, "unsupported operation")
 	case jobtracker.JobControlRelease:
-		return errors.New("unsupported operation")
+		return newError(drmaa2interface.UnsupportedOperation, "unsupported operation")
 	case jobtracker.JobControlTerminate:
-		// there seems no way to stop a job
-		return t.DeleteJob(jobID)
+		// the MPIJob is kept so that JobState and JobInfo are still
+		// available until DeleteJob is called
+		err := TerminateJob(context.Background(), t.clientset, t.kubeClient, t.Namespace(), jobID)
+		if err != nil {
+			return fmt.Errorf("failed to terminate job: %w", err)
+		}
+		t.jobLogger(jobID, "terminate").V(LogLevelOperations).Info("terminated job")
+		return nil
 	}
-	return fmt.Errorf("undefined job operation")
+	return newError(drmaa2interface.InvalidArgument, "undefined job operation")
 }
 
+// waitPollInterval is the interval in which Wait checks the job state.
+var waitPollInterval = 100 * time.Millisecond
+
 // Wait blocks until the job is either in one of the given states, the max.
 // waiting time (specified by timeout) is reached or an other internal
 // error occured (like job was not found). In case of a timeout also an
-// error must be returned.
+// error must be returned. With a job store the job info is recorded when
+// the job is seen in an end state. A timeout is reported as
+// drmaa2interface.Error with the Timeout ID.
 func (t *MPIOperatorTracker) Wait(jobID string, timeout time.Duration, states ...drmaa2interface.JobState) error {
-	return helper.WaitForState(t, jobID, timeout, states...)
+	start := time.Now()
+	for {
+		state, _, err := t.JobState(jobID)
+		if err != nil {
+			return err
+		}
+		if helper.IsInExpectedState(state, states...) {
+			return nil
+		}
+		if time.Since(start) >= timeout {
+			return newError(drmaa2interface.Timeout, "timeout while waiting for job state")
+		}
+		time.Sleep(waitPollInterval)
+	}
 }
 
 // DeleteJob removes a job from a potential internal database. It does not stop
@@ -142,10 +642,33 @@ func (t *MPIOperatorTracker) Wait(jobID string, timeout time.Duration, states ..
 // returned. If the backend does not support cleaning up resources for a finished
 // job nil should be returned.
 func (t *MPIOperatorTracker) DeleteJob(jobID string) error {
-	err := DeleteJob(context.Background(), t.clientset, "default", jobID)
+	return t.DeleteJobWithOptions(jobID, DefaultDeleteJobOptions)
+}
+
+// DeleteJobWithOptions removes a job which is in an end state from the
+// cluster. The options define the propagation policy and if the call
+// blocks until the launcher and worker pods are gone. If the job is not
+// in an end state a *JobNotInEndStateError is returned. Jobs of the job
+// store which were removed from the cluster before they finished can be
+// deleted as well.
+func (t *MPIOperatorTracker) DeleteJobWithOptions(jobID string, opts DeleteJobOptions) error {
+	state, subState, err := t.JobState(jobID)
 	if err != nil {
-		return fmt.Errorf("failed to delete job: %v\n", err)
+		return fmt.Errorf("failed to get job state: %w", err)
+	}
+	if !IsEndState(state) && subState != SubStateRemoved {
+		return &JobNotInEndStateError{JobID: jobID, State: state, SubState: subState}
+	}
+	err = DeleteJobWithOptions(context.Background(), t.clientset, t.kubeClient, t.Namespace(), jobID, opts)
+	if err != nil && !(t.store != nil && apierrors.IsNotFound(err)) {
+		return wrapError(err, "failed to delete job")
+	}
+	if t.store != nil {
+		if err := t.store.Delete(t.Namespace(), jobID); err != nil {
+			return fmt.Errorf("failed to delete job from job store: %v", err)
+		}
 	}
+	t.jobLogger(jobID, "delete").V(LogLevelOperations).Info("deleted job")
 	return nil
 }
 
@@ -153,7 +676,7 @@ func (t *MPIOperatorTracker) DeleteJob(jobID string) error {
 // JobCategory field of the job template. The list is informational. An example
 // is returning a list of supported container images. AddJob() and AddArrayJob()
 // processes a JobTemplate and hence also the JobCategory field.
-func (MPIOperatorTracker) ListJobCategories() ([]string, error) {
+func (t *MPIOperatorTracker) ListJobCategories() ([]string, error) {
 	// all kind of launcher images are supported
 	return []string{}, nil
 }
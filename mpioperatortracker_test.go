@@ -0,0 +1,199 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newFakeManagedMPIJob(name, owner, queue string, created time.Time, conditions ...common.JobConditionType) *kubeflow.MPIJob {
+	job := newFakeMPIJob(name, conditions...)
+	job.Labels = map[string]string{LabelManagedBy: ManagedByValue}
+	SetJobOwner(job, owner)
+	job.CreationTimestamp = metav1.NewTime(created)
+	job.Spec.RunPolicy.SchedulingPolicy = &common.SchedulingPolicy{Queue: queue}
+	return job
+}
+
+var _ = Describe("MPIOperatorTracker", func() {
+
+	Context("Submitting jobs", func() {
+
+		It("should submit jobs with the tracker parameters", func() {
+			tracker := &MPIOperatorTracker{
+				clientset:      fake.NewSimpleClientset(),
+				namespace:      "mpi-jobs",
+				jobSessionName: "session",
+				defaultTemplate: drmaa2interface.JobTemplate{
+					JobCategory:    "mpioperator/mpi-pi:openmpi",
+					MinSlots:       4,
+					JobEnvironment: map[string]string{"A": "default", "B": "default"},
+				},
+			}
+			_, err := tracker.AddJob(drmaa2interface.JobTemplate{
+				JobEnvironment: map[string]string{"A": "job"},
+			})
+			Expect(err).To(BeNil())
+
+			jobs, err := ListJobs(context.Background(), tracker.clientset, "mpi-jobs")
+			Expect(err).To(BeNil())
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].Labels[LabelManagedBy]).To(Equal(ManagedByValue))
+			Expect(jobs[0].Labels[LabelJobSession]).To(Equal("session"))
+			Expect(*jobs[0].Spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeWorker].Replicas).To(BeNumerically("==", 4))
+			launcher := jobs[0].Spec.MPIReplicaSpecs[kubeflow.MPIReplicaTypeLauncher].Template.Spec.Containers[0]
+			Expect(launcher.Image).To(Equal("mpioperator/mpi-pi:openmpi"))
+			Expect(launcher.Env).To(ConsistOf(
+				corev1.EnvVar{Name: "A", Value: "job"},
+				corev1.EnvVar{Name: "B", Value: "default"}))
+
+			// jobs of other sessions are not visible
+			otherSession := &MPIOperatorTracker{
+				clientset:      tracker.clientset,
+				namespace:      "mpi-jobs",
+				jobSessionName: "other",
+			}
+			jobIDs, err := otherSession.ListJobs()
+			Expect(err).To(BeNil())
+			Expect(jobIDs).To(BeEmpty())
+			jobIDs, err = tracker.ListJobs()
+			Expect(err).To(BeNil())
+			Expect(jobIDs).To(HaveLen(1))
+		})
+
+	})
+
+	Context("Listing jobs", func() {
+
+		var tracker *MPIOperatorTracker
+		now := time.Now()
+
+		BeforeEach(func() {
+			tracker = &MPIOperatorTracker{
+				clientset: fake.NewSimpleClientset(
+					newFakeManagedMPIJob("alice-running", "alice", "q1", now.Add(-time.Hour),
+						common.JobCreated, common.JobRunning),
+					newFakeManagedMPIJob("alice-done", "alice", "q2", now.Add(-2*time.Hour),
+						common.JobCreated, common.JobRunning, common.JobSucceeded),
+					newFakeManagedMPIJob("bob-running", "bob", "q1", now,
+						common.JobCreated, common.JobRunning),
+					newFakeMPIJob("unmanaged", common.JobCreated),
+				),
+			}
+		})
+
+		It("should list only jobs created by the tracker", func() {
+			jobs, err := tracker.ListJobs()
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("alice-running", "alice-done", "bob-running"))
+		})
+
+		It("should list all jobs when requested", func() {
+			jobs, _, err := tracker.ListJobsFiltered(ListJobsFilter{AllJobs: true})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(HaveLen(4))
+		})
+
+		It("should filter jobs by job info", func() {
+			filter := drmaa2interface.CreateJobInfo()
+			filter.State = drmaa2interface.Running
+			jobs, _, err := tracker.ListJobsFiltered(ListJobsFilter{JobInfo: &filter})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("alice-running", "bob-running"))
+
+			filter = drmaa2interface.CreateJobInfo()
+			filter.JobOwner = "alice"
+			filter.QueueName = "q1"
+			jobs, _, err = tracker.ListJobsFiltered(ListJobsFilter{JobInfo: &filter})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("alice-running"))
+
+			filter = drmaa2interface.CreateJobInfo()
+			filter.SubmissionTime = now.Add(-90 * time.Minute)
+			jobs, _, err = tracker.ListJobsFiltered(ListJobsFilter{JobInfo: &filter})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("alice-running", "bob-running"))
+
+			jobInfos, _, err := tracker.ListJobInfosFiltered(ListJobsFilter{JobInfo: &filter})
+			Expect(err).To(BeNil())
+			Expect(jobInfos).To(HaveLen(2))
+			Expect(jobInfos[0].State).To(Equal(drmaa2interface.Running))
+		})
+
+		It("should filter jobs by owner names which are not valid label values", func() {
+			_, err := tracker.clientset.KubeflowV2beta1().MPIJobs("default").Create(context.Background(),
+				newFakeManagedMPIJob("domain-user", `DOMAIN\alice`, "q1", now, common.JobCreated),
+				metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+			_, err = tracker.clientset.KubeflowV2beta1().MPIJobs("default").Create(context.Background(),
+				newFakeManagedMPIJob("mail-user", "alice@example.com", "q1", now, common.JobCreated),
+				metav1.CreateOptions{})
+			Expect(err).To(BeNil())
+
+			filter := drmaa2interface.CreateJobInfo()
+			filter.JobOwner = `DOMAIN\alice`
+			jobs, _, err := tracker.ListJobsFiltered(ListJobsFilter{JobInfo: &filter})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("domain-user"))
+
+			filter.JobOwner = "alice@example.com"
+			jobs, _, err = tracker.ListJobsFiltered(ListJobsFilter{JobInfo: &filter})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("mail-user"))
+
+			jobInfo, err := tracker.JobInfo("mail-user")
+			Expect(err).To(BeNil())
+			Expect(jobInfo.JobOwner).To(Equal("alice@example.com"))
+		})
+
+		It("should filter jobs by label selector", func() {
+			jobs, _, err := tracker.ListJobsFiltered(ListJobsFilter{
+				LabelSelector: LabelJobOwner + "=bob",
+			})
+			Expect(err).To(BeNil())
+			Expect(jobs).To(ConsistOf("bob-running"))
+
+			_, _, err = tracker.ListJobsFiltered(ListJobsFilter{LabelSelector: "=invalid"})
+			Expect(err).NotTo(BeNil())
+		})
+
+	})
+
+	Context("Job states", func() {
+
+		It("should report restarts with a retry counter", func() {
+			job := newFakeMPIJob("restarting", common.JobCreated, common.JobRunning)
+			job.Spec.RunPolicy.BackoffLimit = toInt32(3)
+			job.Status.ReplicaStatuses = map[common.ReplicaType]*common.ReplicaStatus{
+				common.ReplicaType(kubeflow.MPIReplicaTypeLauncher): {Failed: 1},
+			}
+			state, subState, err := JobStateFromMPIJob(job)
+			Expect(err).To(BeNil())
+			Expect(state).To(Equal(drmaa2interface.Requeued))
+			Expect(subState).To(Equal("restarting (retry 1 of 3)"))
+
+			job.Status.ReplicaStatuses[common.ReplicaType(kubeflow.MPIReplicaTypeLauncher)].Active = 1
+			state, subState, err = JobStateFromMPIJob(job)
+			Expect(err).To(BeNil())
+			Expect(state).To(Equal(drmaa2interface.Running))
+			Expect(subState).To(Equal("running (retry 1 of 3)"))
+
+			job.Status.Conditions = append(job.Status.Conditions,
+				common.JobCondition{Type: common.JobFailed})
+			state, subState, err = JobStateFromMPIJob(job)
+			Expect(err).To(BeNil())
+			Expect(state).To(Equal(drmaa2interface.Failed))
+			Expect(subState).To(Equal("failed"))
+		})
+
+	})
+
+})
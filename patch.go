@@ -0,0 +1,101 @@
+package mpioperatortracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgruber/drmaa2interface"
+	jsonpatch "github.com/evanphx/json-patch"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+const ExtensionLauncherPodTemplatePatch = "launcherPodTemplatePatch"
+const ExtensionWorkerPodTemplatePatch = "workerPodTemplatePatch"
+
+// SetLauncherPodTemplatePatchExtension sets a patch which is applied on
+// the launcher PodTemplateSpec created by ConvertJobTemplateToMPIJob. See
+// PatchPodTemplate for the supported patch formats.
+func SetLauncherPodTemplatePatchExtension(jt drmaa2interface.JobTemplate, patch string) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionLauncherPodTemplatePatch] = patch
+	return jt
+}
+
+func GetLauncherPodTemplatePatchExtension(jt drmaa2interface.JobTemplate) string {
+	if jt.ExtensionList == nil {
+		return ""
+	}
+	return jt.ExtensionList[ExtensionLauncherPodTemplatePatch]
+}
+
+// SetWorkerPodTemplatePatchExtension sets a patch which is applied on
+// the worker PodTemplateSpec created by ConvertJobTemplateToMPIJob. See
+// PatchPodTemplate for the supported patch formats.
+func SetWorkerPodTemplatePatchExtension(jt drmaa2interface.JobTemplate, patch string) drmaa2interface.JobTemplate {
+	if jt.ExtensionList == nil {
+		jt.ExtensionList = make(map[string]string)
+	}
+	jt.ExtensionList[ExtensionWorkerPodTemplatePatch] = patch
+	return jt
+}
+
+func GetWorkerPodTemplatePatchExtension(jt drmaa2interface.JobTemplate) string {
+	if jt.ExtensionList == nil {
+		return ""
+	}
+	return jt.ExtensionList[ExtensionWorkerPodTemplatePatch]
+}
+
+// PatchPodTemplate applies a patch in JSON or YAML format on the pod
+// template. A list is treated as JSON patch (RFC 6902), like
+//
+//	[{"op": "add", "path": "/spec/hostIPC", "value": true}]
+//
+// an object is treated as strategic merge patch, like
+//
+//	{"spec": {"containers": [{"name": "sidecar", "image": "busybox"}]}}
+//
+// where lists like containers are merged by their names. An empty patch
+// returns the unmodified template.
+func PatchPodTemplate(template v1.PodTemplateSpec, patch string) (v1.PodTemplateSpec, error) {
+	if len(bytes.TrimSpace([]byte(patch))) == 0 {
+		return template, nil
+	}
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return template, fmt.Errorf("failed to parse pod template patch: %v", err)
+	}
+	original, err := json.Marshal(template)
+	if err != nil {
+		return template, err
+	}
+	var patched []byte
+	switch patchJSON[0] {
+	case '[':
+		jsonPatch, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return template, fmt.Errorf("invalid JSON patch: %v", err)
+		}
+		patched, err = jsonPatch.Apply(original)
+		if err != nil {
+			return template, fmt.Errorf("failed to apply JSON patch: %v", err)
+		}
+	case '{':
+		patched, err = strategicpatch.StrategicMergePatch(original, patchJSON, v1.PodTemplateSpec{})
+		if err != nil {
+			return template, fmt.Errorf("failed to apply strategic merge patch: %v", err)
+		}
+	default:
+		return template, fmt.Errorf("pod template patch must be a list (JSON patch) or an object (strategic merge patch)")
+	}
+	var result v1.PodTemplateSpec
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return template, fmt.Errorf("patched pod template is invalid: %v", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,192 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// MPIJobCRDName is the name of the MPIJob CustomResourceDefinition.
+	MPIJobCRDName = "mpijobs.kubeflow.org"
+	// OperatorNamespace is the namespace of the embedded manifest in
+	// which the MPI operator runs.
+	OperatorNamespace = "mpi-operator"
+	// OperatorDeploymentName is the name of the MPI operator deployment.
+	OperatorDeploymentName = "mpi-operator"
+	// DefaultReadyTimeout is the default max. time to wait for the MPI
+	// operator to become ready after installation.
+	DefaultReadyTimeout = 2 * time.Minute
+)
+
+var crdResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// OperatorCrashLoopError is returned when the MPI operator pod does not
+// start successfully.
+type OperatorCrashLoopError struct {
+	Pod      string
+	Reason   string
+	Message  string
+	ExitCode int32
+}
+
+func (e *OperatorCrashLoopError) Error() string {
+	msg := fmt.Sprintf("MPI operator pod %s is not starting (%s)", e.Pod, e.Reason)
+	if e.ExitCode != 0 {
+		msg += fmt.Sprintf(", last exit code %d", e.ExitCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// WaitForMPIOperator blocks until the MPIJob CRD is established and the
+// MPI operator deployment in the given namespace is rolled out (its latest
+// generation is observed, all replicas are updated, and replicas are
+// available). It returns an OperatorCrashLoopError as soon as an operator
+// pod is in CrashLoopBackOff or ImagePullBackOff, and stops waiting
+// when the context is cancelled.
+func WaitForMPIOperator(ctx context.Context, dynamicClient dynamic.Interface, kubeClient kubernetes.Interface, namespace string, timeout time.Duration) error {
+	if timeout == 0 {
+		timeout = DefaultReadyTimeout
+	}
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var lastState string
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		established, err := isCRDEstablished(pollCtx, dynamicClient, MPIJobCRDName)
+		if err != nil {
+			return false, err
+		}
+		if !established {
+			lastState = fmt.Sprintf("CRD %s is not established", MPIJobCRDName)
+			return false, nil
+		}
+		available, err := isOperatorAvailable(pollCtx, kubeClient, namespace)
+		if err != nil {
+			return false, err
+		}
+		if !available {
+			lastState = fmt.Sprintf("deployment %s/%s is not rolled out",
+				namespace, OperatorDeploymentName)
+		}
+		return available, nil
+	}, pollCtx.Done())
+	// a request which is cancelled by the timeout fails with its own error
+	if err == wait.ErrWaitTimeout || (err != nil && pollCtx.Err() != nil) {
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped waiting for the MPI operator: %v", ctx.Err())
+		}
+		return fmt.Errorf("MPI operator is not ready after %v: %s", timeout, lastState)
+	}
+	return err
+}
+
+func isCRDEstablished(ctx context.Context, dynamicClient dynamic.Interface, name string) (bool, error) {
+	crd, err := dynamicClient.Resource(crdResource).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get CRD %s: %v", name, err)
+	}
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Established" && condition["status"] == "True" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func isOperatorAvailable(ctx context.Context, kubeClient kubernetes.Interface, namespace string) (bool, error) {
+	deployment, err := kubeClient.AppsV1().Deployments(namespace).Get(ctx,
+		OperatorDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get MPI operator deployment: %v", err)
+	}
+	if isDeploymentRolledOut(deployment) {
+		return true, nil
+	}
+	if deployment.Spec.Selector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return false, err
+	}
+	pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx,
+		metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return false, fmt.Errorf("failed to list MPI operator pods: %v", err)
+	}
+	for i := range pods.Items {
+		if err := crashLoopError(&pods.Items[i]); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// isDeploymentRolledOut returns true if the deployment controller has
+// observed the latest generation, all replicas run the latest pod
+// template, and replicas are available.
+func isDeploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.AvailableReplicas > 0
+}
+
+// crashLoopError returns an error if a container of the pod is waiting
+// for a reason it won't recover from without intervention. ErrImagePull
+// is not an error as it is reported for the first failed pull of an image
+// which is retried (then in ImagePullBackOff).
+func crashLoopError(pod *corev1.Pod) error {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		switch status.State.Waiting.Reason {
+		case "CrashLoopBackOff", "ImagePullBackOff", "InvalidImageName":
+			crashLoop := &OperatorCrashLoopError{
+				Pod:     pod.Name,
+				Reason:  status.State.Waiting.Reason,
+				Message: status.State.Waiting.Message,
+			}
+			if terminated := status.LastTerminationState.Terminated; terminated != nil {
+				crashLoop.ExitCode = terminated.ExitCode
+				if terminated.Message != "" {
+					crashLoop.Message = terminated.Message
+				}
+			}
+			return crashLoop
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newFakeCRD(established bool) *unstructured.Unstructured {
+	status := "False"
+	if established {
+		status = "True"
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": MPIJobCRDName},
+		"status": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Established", "status": status},
+			},
+		},
+	}}
+}
+
+func newFakeOperatorDeployment(available int32) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorDeploymentName, Namespace: OperatorNamespace,
+			Generation: 1},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "mpi-operator"}},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			UpdatedReplicas:    available,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+var _ = Describe("Waiting for the MPI operator", func() {
+
+	It("should return when the CRD is established and the operator is available", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newFakeCRD(true))
+		kubeClient := k8sfake.NewSimpleClientset(newFakeOperatorDeployment(1))
+		err := WaitForMPIOperator(context.Background(), dynamicClient, kubeClient,
+			OperatorNamespace, 5*time.Second)
+		Expect(err).To(BeNil())
+	})
+
+	It("should time out when the CRD is not established", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newFakeCRD(false))
+		kubeClient := k8sfake.NewSimpleClientset(newFakeOperatorDeployment(1))
+		err := WaitForMPIOperator(context.Background(), dynamicClient, kubeClient,
+			OperatorNamespace, 10*time.Millisecond)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("not established"))
+	})
+
+	It("should wait until the operator deployment is rolled out", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newFakeCRD(true))
+		// an old replica is available while the new generation is rolled out
+		deployment := newFakeOperatorDeployment(1)
+		deployment.Generation = 2
+		kubeClient := k8sfake.NewSimpleClientset(deployment)
+		err := WaitForMPIOperator(context.Background(), dynamicClient, kubeClient,
+			OperatorNamespace, 10*time.Millisecond)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("not rolled out"))
+
+		deployment = newFakeOperatorDeployment(1)
+		deployment.Status.UpdatedReplicas = 0
+		kubeClient = k8sfake.NewSimpleClientset(deployment)
+		err = WaitForMPIOperator(context.Background(), dynamicClient, kubeClient,
+			OperatorNamespace, 10*time.Millisecond)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("not rolled out"))
+	})
+
+	It("should stop waiting when the context is cancelled", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newFakeCRD(false))
+		kubeClient := k8sfake.NewSimpleClientset(newFakeOperatorDeployment(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+		err := WaitForMPIOperator(ctx, dynamicClient, kubeClient, OperatorNamespace, time.Minute)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("context canceled"))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("should fail when the operator pod crashloops", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newFakeCRD(true))
+		kubeClient := k8sfake.NewSimpleClientset(newFakeOperatorDeployment(0), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mpi-operator-1234",
+				Namespace: OperatorNamespace,
+				Labels:    map[string]string{"app": "mpi-operator"},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+					},
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 2, Message: "unknown flag"},
+					},
+				}},
+			},
+		})
+		err := WaitForMPIOperator(context.Background(), dynamicClient, kubeClient,
+			OperatorNamespace, 5*time.Second)
+		Expect(err).NotTo(BeNil())
+		crashLoop, ok := err.(*OperatorCrashLoopError)
+		Expect(ok).To(BeTrue())
+		Expect(crashLoop.ExitCode).To(BeNumerically("==", 2))
+		Expect(err.Error()).To(ContainSubstring("unknown flag"))
+	})
+
+	It("should keep waiting when the image of the operator pod is pulled again", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newFakeCRD(true))
+		kubeClient := k8sfake.NewSimpleClientset(newFakeOperatorDeployment(0), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mpi-operator-1234",
+				Namespace: OperatorNamespace,
+				Labels:    map[string]string{"app": "mpi-operator"},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					State: corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"},
+					},
+				}},
+			},
+		})
+		err := WaitForMPIOperator(context.Background(), dynamicClient, kubeClient,
+			OperatorNamespace, 10*time.Millisecond)
+		Expect(err).NotTo(BeNil())
+		Expect(err).NotTo(BeAssignableToTypeOf(&OperatorCrashLoopError{}))
+		Expect(err.Error()).To(ContainSubstring("not rolled out"))
+	})
+
+})
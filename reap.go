@@ -0,0 +1,116 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReapOptions define which finished jobs are removed by Reap.
+type ReapOptions struct {
+	// OlderThan is the min. time since the job finished. 0 removes all
+	// finished jobs.
+	OlderThan time.Duration
+	// Archive is called with the job info of each job before it is
+	// deleted. If it returns an error the job is not deleted. nil
+	// deletes the jobs without archiving them. Note that with a job
+	// store the job info is archived in the store in any case.
+	Archive func(drmaa2interface.JobInfo) error
+	// DeleteOptions define how the jobs are deleted.
+	DeleteOptions DeleteJobOptions
+}
+
+// FinishTime returns the time when the MPIJob finished. ok is false when
+// the job is not finished.
+func FinishTime(mpiJob *kubeflow.MPIJob) (finished time.Time, ok bool) {
+	if !isFinished(mpiJob) {
+		return time.Time{}, false
+	}
+	if mpiJob.Status.CompletionTime != nil {
+		return mpiJob.Status.CompletionTime.Time, true
+	}
+	for _, condition := range mpiJob.Status.Conditions {
+		if condition.Status == v1.ConditionFalse {
+			continue
+		}
+		if condition.LastTransitionTime.After(finished) {
+			finished = condition.LastTransitionTime.Time
+		}
+	}
+	return finished, true
+}
+
+// IsReapable returns true if the MPIJob is finished at least olderThan
+// before now.
+func IsReapable(mpiJob *kubeflow.MPIJob, olderThan time.Duration, now time.Time) bool {
+	finished, ok := FinishTime(mpiJob)
+	if !ok {
+		return false
+	}
+	return !finished.Add(olderThan).After(now)
+}
+
+// Reap deletes all finished jobs of the tracker (and its job session)
+// which finished before opts.OlderThan. As the MPI operator does not
+// remove finished MPIJobs (TTLSecondsAfterFinished only applies to the
+// launcher Job), Reap is the way to clean them up. It returns the IDs of
+// the deleted jobs. Jobs which failed to be archived or deleted are skipped
+// and reported in the returned error.
+func (t *MPIOperatorTracker) Reap(opts ReapOptions) ([]string, error) {
+	selector, err := t.jobSelector("", false)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var reaped []string
+	var failed []string
+	continueToken := ""
+	for {
+		jobs, err := ListJobsWithOptions(context.Background(), t.clientset, t.Namespace(),
+			metav1.ListOptions{
+				LabelSelector: selector.String(),
+				Continue:      continueToken,
+			})
+		if err != nil {
+			return reaped, fmt.Errorf("failed to list MPIOperator jobs: %v", err)
+		}
+		for i := range jobs.Items {
+			job := &jobs.Items[i]
+			if !IsReapable(job, opts.OlderThan, now) {
+				continue
+			}
+			if err := t.reapJob(job, opts); err != nil {
+				t.jobLogger(job.Name, "reap").Error(err, "failed to reap job")
+				failed = append(failed, fmt.Sprintf("%s: %v", job.Name, err))
+				continue
+			}
+			t.jobLogger(job.Name, "reap").V(LogLevelOperations).Info("reaped job")
+			reaped = append(reaped, job.Name)
+		}
+		if jobs.Continue == "" {
+			break
+		}
+		continueToken = jobs.Continue
+	}
+	if len(failed) > 0 {
+		return reaped, fmt.Errorf("failed to reap jobs: %v", failed)
+	}
+	return reaped, nil
+}
+
+func (t *MPIOperatorTracker) reapJob(job *kubeflow.MPIJob, opts ReapOptions) error {
+	jobInfo := JobInfoFromMPIJob(job)
+	t.recordJobInfo(jobInfo)
+	if opts.Archive != nil {
+		if err := opts.Archive(jobInfo); err != nil {
+			return fmt.Errorf("failed to archive job info: %v", err)
+		}
+	}
+	return DeleteJobWithOptions(context.Background(), t.clientset, t.kubeClient,
+		job.Namespace, job.Name, opts.DeleteOptions)
+}
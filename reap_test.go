@@ -0,0 +1,102 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newFakeFinishedMPIJob(name string, finished time.Time, condition common.JobConditionType) *kubeflow.MPIJob {
+	job := newFakeManagedMPIJob(name, "user", "", finished.Add(-time.Minute),
+		common.JobCreated, common.JobRunning, condition)
+	completion := metav1.NewTime(finished)
+	job.Status.CompletionTime = &completion
+	return job
+}
+
+var _ = Describe("Reap", func() {
+
+	var tracker *MPIOperatorTracker
+
+	BeforeEach(func() {
+		now := time.Now()
+		unmanaged := newFakeMPIJob("unmanaged", common.JobSucceeded)
+		unmanaged.Status.CompletionTime = &metav1.Time{Time: now.Add(-time.Hour)}
+		tracker = &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(
+				newFakeFinishedMPIJob("old-succeeded", now.Add(-time.Hour), common.JobSucceeded),
+				newFakeFinishedMPIJob("old-failed", now.Add(-time.Hour), common.JobFailed),
+				newFakeFinishedMPIJob("recent", now.Add(-time.Second), common.JobSucceeded),
+				newFakeManagedMPIJob("running", "user", "", now.Add(-time.Hour),
+					common.JobCreated, common.JobRunning),
+				unmanaged,
+			),
+			kubeClient: k8sfake.NewSimpleClientset(),
+		}
+	})
+
+	It("should delete finished jobs of the tracker older than the threshold", func() {
+		var archived []drmaa2interface.JobInfo
+		reaped, err := tracker.Reap(ReapOptions{
+			OlderThan: time.Minute,
+			Archive: func(jobInfo drmaa2interface.JobInfo) error {
+				archived = append(archived, jobInfo)
+				return nil
+			},
+		})
+		Expect(err).To(BeNil())
+		Expect(reaped).To(ConsistOf("old-succeeded", "old-failed"))
+		Expect(archived).To(HaveLen(2))
+
+		jobs, err := ListJobs(context.Background(), tracker.clientset, "default")
+		Expect(err).To(BeNil())
+		Expect(jobs).To(HaveLen(3))
+	})
+
+	It("should not delete jobs which failed to be archived", func() {
+		reaped, err := tracker.Reap(ReapOptions{
+			Archive: func(jobInfo drmaa2interface.JobInfo) error {
+				if jobInfo.ID == "recent" {
+					return errors.New("archive not available")
+				}
+				return nil
+			},
+		})
+		Expect(err).NotTo(BeNil())
+		Expect(reaped).To(ConsistOf("old-succeeded", "old-failed"))
+		_, err = DescribeJob(context.Background(), tracker.clientset, "default", "recent")
+		Expect(err).To(BeNil())
+	})
+
+	It("should set the default TTL for jobs without TTL extension", func() {
+		tracker.defaultTTL = toInt32(600)
+		jobID, err := tracker.AddJob(drmaa2interface.JobTemplate{
+			JobCategory: "mpioperator/mpi-pi:intel",
+			MinSlots:    1,
+		})
+		Expect(err).To(BeNil())
+		job, err := DescribeJob(context.Background(), tracker.clientset, "default", jobID)
+		Expect(err).To(BeNil())
+		Expect(*job.Spec.RunPolicy.TTLSecondsAfterFinished).To(BeNumerically("==", 600))
+
+		spec, err := ConvertJobTemplateToMPIJob(SetTTLSecondsAfterFinishedExtension(
+			drmaa2interface.JobTemplate{
+				JobCategory: "mpioperator/mpi-pi:intel",
+				MinSlots:    1,
+			}, 60))
+		Expect(err).To(BeNil())
+		Expect(*spec.RunPolicy.TTLSecondsAfterFinished).To(BeNumerically("==", 60))
+		mpiJob := tracker.newMPIJob(spec)
+		Expect(*mpiJob.Spec.RunPolicy.TTLSecondsAfterFinished).To(BeNumerically("==", 60))
+	})
+
+})
@@ -0,0 +1,127 @@
+// Package register plugs the MPIOperatorTracker into the drmaa2os
+// SessionManager so that MPI operator jobs can be managed through DRMAA2
+// job and monitoring sessions.
+//
+// drmaa2os does not define a session type for the MPI operator, hence the
+// tracker is registered as drmaa2os.ExternalSession. A SessionManager is
+// created with NewMPIOperatorSessionManager:
+//
+//	sm, err := register.NewMPIOperatorSessionManager(
+//		mpioperatortracker.MPIOperatorTrackerParams{
+//			Namespace: "mpi-jobs",
+//		}, "drmaa2.db")
+//	js, err := sm.CreateJobSession("jobsession", "")
+package register
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker"
+	"github.com/dgruber/mpioperatortracker"
+)
+
+// MPIOperatorSession is the drmaa2os session type the tracker is
+// registered for.
+const MPIOperatorSession = drmaa2os.ExternalSession
+
+// Allocator creates an MPIOperatorTracker for each job and monitoring
+// session of a drmaa2os SessionManager.
+type Allocator struct {
+	params mpioperatortracker.MPIOperatorTrackerParams
+}
+
+// NewAllocator returns an allocator which creates trackers with the given
+// parameters. It can be registered for the MPIOperatorSession type with
+// drmaa2os.RegisterJobTracker when the SessionManager is created by
+// drmaa2os.NexExternalSessionManager.
+func NewAllocator(params mpioperatortracker.MPIOperatorTrackerParams) *Allocator {
+	return &Allocator{params: params}
+}
+
+// New is called by the SessionManager when a new JobSession is allocated.
+// The jobTrackerInitParams are either nil, or of type
+// mpioperatortracker.MPIOperatorTrackerParams. When nil the parameters
+// of the allocator are used. The job session name is used as
+// JobSessionName when not set in the parameters.
+func (a *Allocator) New(jobSessionName string, jobTrackerInitParams interface{}) (jobtracker.JobTracker, error) {
+	params := a.params
+	if jobTrackerInitParams != nil {
+		switch p := jobTrackerInitParams.(type) {
+		case mpioperatortracker.MPIOperatorTrackerParams:
+			params = p
+		case *mpioperatortracker.MPIOperatorTrackerParams:
+			params = *p
+		default:
+			return nil, errors.New("jobTrackerInitParams for the MPI operator have not MPIOperatorTrackerParams type")
+		}
+	}
+	if params.JobSessionName == "" {
+		params.JobSessionName = jobSessionName
+	}
+	return mpioperatortracker.NewMPIOperatorTrackerWithParams(params)
+}
+
+// allocatorMu serializes the registration of the allocator of a session
+// manager and the creation of its tracker, as drmaa2os keeps only one
+// allocator per session type.
+var allocatorMu sync.Mutex
+
+// SessionManager is a drmaa2os SessionManager which creates the trackers
+// of its sessions with its own parameters.
+type SessionManager struct {
+	*drmaa2os.SessionManager
+	allocator *Allocator
+}
+
+// NewMPIOperatorSessionManager creates a drmaa2os SessionManager which
+// manages jobs as MPIJobs. The session names are stored in the database
+// at dbpath. The parameters are used for all trackers created by the
+// session manager; session managers in the same process can use
+// different parameters.
+func NewMPIOperatorSessionManager(params mpioperatortracker.MPIOperatorTrackerParams, dbpath string) (*SessionManager, error) {
+	sm, err := drmaa2os.NexExternalSessionManager(dbpath)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionManager{SessionManager: sm, allocator: NewAllocator(params)}, nil
+}
+
+// withAllocator registers the allocator of the session manager while
+// sessions are created.
+func (sm *SessionManager) withAllocator(create func() error) error {
+	allocatorMu.Lock()
+	defer allocatorMu.Unlock()
+	drmaa2os.RegisterJobTracker(MPIOperatorSession, sm.allocator)
+	return create()
+}
+
+// CreateJobSession creates a new JobSession for managing jobs.
+func (sm *SessionManager) CreateJobSession(name, contact string) (js drmaa2interface.JobSession, err error) {
+	err = sm.withAllocator(func() error {
+		js, err = sm.SessionManager.CreateJobSession(name, contact)
+		return err
+	})
+	return js, err
+}
+
+// OpenJobSession opens an existing JobSession.
+func (sm *SessionManager) OpenJobSession(name string) (js drmaa2interface.JobSession, err error) {
+	err = sm.withAllocator(func() error {
+		js, err = sm.SessionManager.OpenJobSession(name)
+		return err
+	})
+	return js, err
+}
+
+// OpenMonitoringSession opens a session for monitoring the MPIJobs of
+// all namespaces.
+func (sm *SessionManager) OpenMonitoringSession(name string) (ms drmaa2interface.MonitoringSession, err error) {
+	err = sm.withAllocator(func() error {
+		ms, err = sm.SessionManager.OpenMonitoringSession(name)
+		return err
+	})
+	return ms, err
+}
@@ -0,0 +1,13 @@
+package register_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRegister(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Register Suite")
+}
@@ -0,0 +1,113 @@
+package register_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dgruber/mpioperatortracker"
+	. "github.com/dgruber/mpioperatortracker/register"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// kubeconfig pointing to a non existing cluster; creating the
+// tracker does not contact the cluster when the operator check
+// is skipped
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`
+
+var _ = Describe("Register", func() {
+
+	var tmpDir string
+	var params mpioperatortracker.MPIOperatorTrackerParams
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "register")
+		Expect(err).To(BeNil())
+		kubeconfigPath := filepath.Join(tmpDir, "kubeconfig")
+		err = os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600)
+		Expect(err).To(BeNil())
+		params = mpioperatortracker.MPIOperatorTrackerParams{
+			KubeconfigPath:    kubeconfigPath,
+			Namespace:         "mpi-jobs",
+			SkipOperatorCheck: true,
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	It("should create job sessions through the session manager", func() {
+		sm, err := NewMPIOperatorSessionManager(params, filepath.Join(tmpDir, "drmaa2.db"))
+		Expect(err).To(BeNil())
+
+		js, err := sm.CreateJobSession("jobsession", "")
+		Expect(err).To(BeNil())
+		Expect(js).NotTo(BeNil())
+		Expect(js.Close()).To(BeNil())
+
+		names, err := sm.GetJobSessionNames()
+		Expect(err).To(BeNil())
+		Expect(names).To(ContainElement("jobsession"))
+
+		js, err = sm.OpenJobSession("jobsession")
+		Expect(err).To(BeNil())
+		Expect(js.Close()).To(BeNil())
+
+		ms, err := sm.OpenMonitoringSession("monitoring")
+		Expect(err).To(BeNil())
+		Expect(ms.CloseMonitoringSession()).To(BeNil())
+
+		Expect(sm.DestroyJobSession("jobsession")).To(BeNil())
+	})
+
+	It("should keep the parameters per session manager", func() {
+		sm, err := NewMPIOperatorSessionManager(params, filepath.Join(tmpDir, "drmaa2.db"))
+		Expect(err).To(BeNil())
+		broken := params
+		broken.KubeconfigPath = filepath.Join(tmpDir, "missing")
+		other, err := NewMPIOperatorSessionManager(broken, filepath.Join(tmpDir, "other.db"))
+		Expect(err).To(BeNil())
+
+		_, err = other.CreateJobSession("other", "")
+		Expect(err).NotTo(BeNil())
+		js, err := sm.CreateJobSession("jobsession", "")
+		Expect(err).To(BeNil())
+		Expect(js.Close()).To(BeNil())
+	})
+
+	It("should create a tracker with the given parameters", func() {
+		tracker, err := NewAllocator(params).New("session", nil)
+		Expect(err).To(BeNil())
+		Expect(tracker.(*mpioperatortracker.MPIOperatorTracker).Namespace()).To(Equal("mpi-jobs"))
+
+		override := params
+		override.Namespace = "other"
+		tracker, err = NewAllocator(params).New("session", override)
+		Expect(err).To(BeNil())
+		Expect(tracker.(*mpioperatortracker.MPIOperatorTracker).Namespace()).To(Equal("other"))
+	})
+
+	It("should reject parameters of a wrong type", func() {
+		_, err := NewAllocator(params).New("session", "wrong")
+		Expect(err).NotTo(BeNil())
+	})
+
+})
@@ -0,0 +1,446 @@
+// Package server exposes the JobTracker API of the MPIOperatorTracker as
+// JSON REST API, so that MPI jobs can be submitted and managed by clients
+// without Kubernetes credentials. The API is described by the OpenAPI
+// specification served at /openapi.yaml.
+//
+//	tracker, err := mpioperatortracker.NewMPIOperatorTrackerWithParams(params)
+//	http.ListenAndServe(":8080", server.New(tracker, server.Options{Token: token}))
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/mpioperatortracker"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// JobTracker are the methods of the MPIOperatorTracker exposed by the
+// server.
+type JobTracker interface {
+	AddJob(jt drmaa2interface.JobTemplate) (string, error)
+	JobState(jobID string) (drmaa2interface.JobState, string, error)
+	JobInfo(jobID string) (drmaa2interface.JobInfo, error)
+	JobControl(jobID, action string) error
+	ListJobInfosFiltered(filter mpioperatortracker.ListJobsFilter) ([]drmaa2interface.JobInfo, string, error)
+	DeleteJob(jobID string) error
+}
+
+// Options configure the server.
+type Options struct {
+	// Token is the bearer token clients must send in the Authorization
+	// header. Without a token all requests are rejected unless Insecure
+	// is set.
+	Token string
+	// Insecure serves requests without authentication when no token is
+	// set.
+	Insecure bool
+	// AllowedExtensions are the job template extensions clients may set.
+	// An entry ending with "*" allows all extensions with that prefix
+	// (like "resourceLimitWorker-*"). nil allows the
+	// DefaultAllowedExtensions.
+	AllowedExtensions []string
+	// AllowStageInFiles allows clients to mount persistent volume claims
+	// and config maps of the namespace with StageInFiles.
+	AllowStageInFiles bool
+	// PollInterval is the interval in which the job state is checked
+	// while waiting. Default is one second.
+	PollInterval time.Duration
+	// MaxWait is the max. time a wait request blocks before the
+	// current state is returned. Default is five minutes.
+	MaxWait time.Duration
+}
+
+// DefaultAllowedExtensions are the job template extensions clients may
+// set when no AllowedExtensions are configured. They don't change the
+// security settings of the pods: runAsUser and the pod template patches,
+// which can change any setting of the pods (like mounting host paths or
+// running privileged containers), need to be allowed explicitly.
+var DefaultAllowedExtensions = []string{
+	mpioperatortracker.ExtensionWorkerImage,
+	mpioperatortracker.ExtensionWorkerCommand,
+	mpioperatortracker.ExtensionSlotsPerWorker,
+	mpioperatortracker.ExtensionMPIImplementation,
+	mpioperatortracker.ExtensionSSHMountPath,
+	mpioperatortracker.ExtensionTTLSecondsAfterFinished,
+	mpioperatortracker.ExtensionCleanPodPolicy,
+	mpioperatortracker.ExtensionRetries,
+	mpioperatortracker.ExtensionLauncherRestartPolicy,
+	mpioperatortracker.ExtensionWorkerRestartPolicy,
+	mpioperatortracker.ExtensionElastic,
+	mpioperatortracker.ExtensionSlotMode,
+	mpioperatortracker.ExtensionSlotsFromCPU,
+	mpioperatortracker.ExtensionCoresPerSlot,
+	"resourceLimitLauncher-*",
+	"resourceLimitWorker-*",
+	"resourceRequestLauncher-*",
+	"resourceRequestWorker-*",
+}
+
+// Server is an http.Handler serving the REST API.
+type Server struct {
+	tracker JobTracker
+	opts    Options
+	mux     *http.ServeMux
+}
+
+// New returns a server for the tracker.
+func New(tracker JobTracker, opts Options) *Server {
+	if opts.PollInterval == 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.MaxWait == 0 {
+		opts.MaxWait = 5 * time.Minute
+	}
+	s := &Server{tracker: tracker, opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/openapi.yaml", s.handleOpenAPI)
+	s.mux.HandleFunc("/v1/jobs", s.handleJobs)
+	s.mux.HandleFunc("/v1/jobs/", s.handleJob)
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/openapi.yaml" && !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized returns true if the request contains the bearer token or
+// no token is required.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.opts.Token == "" {
+		return s.opts.Insecure
+	}
+	expected := []byte("Bearer " + s.opts.Token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
+
+// extensionAllowed returns true if clients may set the job template
+// extension.
+func (s *Server) extensionAllowed(extension string) bool {
+	allowedExtensions := s.opts.AllowedExtensions
+	if allowedExtensions == nil {
+		allowedExtensions = DefaultAllowedExtensions
+	}
+	for _, allowed := range allowedExtensions {
+		if prefix := strings.TrimSuffix(allowed, "*"); prefix != allowed {
+			if strings.HasPrefix(extension, prefix) {
+				return true
+			}
+		} else if extension == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+// handleJobs serves /v1/jobs.
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listJobs(w, r)
+	case http.MethodPost:
+		s.addJob(w, r)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleJob serves /v1/jobs/{id} and its sub-resources.
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/jobs/"), "/")
+	jobID := parts[0]
+	if jobID == "" {
+		writeError(w, http.StatusNotFound, errors.New("job ID missing"))
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			s.jobInfo(w, r, jobID)
+		case http.MethodDelete:
+			s.deleteJob(w, r, jobID)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case len(parts) == 2 && parts[1] == "state":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.jobState(w, r, jobID)
+	case len(parts) == 2 && parts[1] == "wait":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.wait(w, r, jobID)
+	case len(parts) == 3 && parts[1] == "control":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		s.jobControl(w, r, jobID, parts[2])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown resource %s", r.URL.Path))
+	}
+}
+
+func (s *Server) addJob(w http.ResponseWriter, r *http.Request) {
+	var jt JobTemplate
+	if err := json.NewDecoder(r.Body).Decode(&jt); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid job template: %v", err))
+		return
+	}
+	for extension := range jt.Extensions {
+		if !s.extensionAllowed(extension) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("extension %q is not allowed", extension))
+			return
+		}
+	}
+	if len(jt.StageInFiles) > 0 && !s.opts.AllowStageInFiles {
+		writeError(w, http.StatusForbidden, errors.New("stageInFiles are not allowed"))
+		return
+	}
+	template := jt.JobTemplate
+	template.ExtensionList = jt.Extensions
+	jobID, err := s.tracker.AddJob(template)
+	if err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+jobID)
+	writeJSON(w, http.StatusCreated, JobState{ID: jobID})
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := mpioperatortracker.ListJobsFilter{
+		LabelSelector: query.Get("selector"),
+		AllJobs:       query.Get("all") == "true",
+		Continue:      query.Get("continue"),
+	}
+	if stateName := query.Get("state"); stateName != "" {
+		state, err := ParseJobState(stateName)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		jobInfo := drmaa2interface.CreateJobInfo()
+		jobInfo.State = state
+		filter.JobInfo = &jobInfo
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if _, err := fmt.Sscanf(limit, "%d", &filter.Limit); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", limit))
+			return
+		}
+	}
+	jobInfos, next, err := s.tracker.ListJobInfosFiltered(filter)
+	if err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	sort.Slice(jobInfos, func(i, j int) bool {
+		return jobInfos[i].ID < jobInfos[j].ID
+	})
+	list := JobList{Jobs: make([]JobInfo, 0, len(jobInfos)), Continue: next}
+	for _, ji := range jobInfos {
+		list.Jobs = append(list.Jobs, NewJobInfo(ji))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) jobInfo(w http.ResponseWriter, r *http.Request, jobID string) {
+	ji, err := s.tracker.JobInfo(jobID)
+	if err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, NewJobInfo(ji))
+}
+
+func (s *Server) jobState(w http.ResponseWriter, r *http.Request, jobID string) {
+	state, subState, err := s.tracker.JobState(jobID)
+	if err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, JobState{ID: jobID, State: state.String(), SubState: subState})
+}
+
+func (s *Server) jobControl(w http.ResponseWriter, r *http.Request, jobID, action string) {
+	if err := s.tracker.JobControl(jobID, action); err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteJob(w http.ResponseWriter, r *http.Request, jobID string) {
+	if err := s.tracker.DeleteJob(jobID); err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// wait blocks until the job is in one of the requested states (default
+// Done and Failed) or the timeout is reached (long-poll). With "Accept:
+// text/event-stream" each state change is sent as server-sent event.
+func (s *Server) wait(w http.ResponseWriter, r *http.Request, jobID string) {
+	query := r.URL.Query()
+	states := []drmaa2interface.JobState{drmaa2interface.Done, drmaa2interface.Failed}
+	if names := query["state"]; len(names) > 0 {
+		states = states[:0]
+		for _, name := range names {
+			state, err := ParseJobState(name)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			states = append(states, state)
+		}
+	}
+	timeout := s.opts.MaxWait
+	if t := query.Get("timeout"); t != "" {
+		var err error
+		timeout, err = time.ParseDuration(t)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid timeout %q", t))
+			return
+		}
+		if timeout > s.opts.MaxWait {
+			timeout = s.opts.MaxWait
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		s.waitEvents(ctx, w, jobID, states)
+		return
+	}
+	result, err := s.waitForState(ctx, jobID, states, nil)
+	if err != nil {
+		writeTrackerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// waitEvents sends a "state" event for the current state and each state
+// change and a final "done" event when one of the states is reached or
+// the timeout occurs.
+func (s *Server) waitEvents(ctx context.Context, w http.ResponseWriter, jobID string, states []drmaa2interface.JobState) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("streaming is not supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(event string, value interface{}) {
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+	result, err := s.waitForState(ctx, jobID, states, func(state JobState) {
+		send("state", state)
+	})
+	if err != nil {
+		send("error", Error{Error: err.Error()})
+		return
+	}
+	send("done", result)
+}
+
+// waitForState polls the job state until it is in one of the states or
+// the context is done. onChange is called for the first state and each
+// change.
+func (s *Server) waitForState(ctx context.Context, jobID string, states []drmaa2interface.JobState, onChange func(JobState)) (WaitResult, error) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	var last JobState
+	for {
+		state, subState, err := s.tracker.JobState(jobID)
+		if err != nil {
+			return WaitResult{}, err
+		}
+		current := JobState{ID: jobID, State: state.String(), SubState: subState}
+		if onChange != nil && current != last {
+			onChange(current)
+		}
+		last = current
+		for _, expected := range states {
+			if state == expected {
+				return WaitResult{JobState: current, Reached: true}, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return WaitResult{JobState: current}, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: strings.TrimSpace(err.Error())})
+}
+
+// writeTrackerError writes an error of the tracker with the HTTP status
+// matching its type or DRMAA2 error ID.
+func writeTrackerError(w http.ResponseWriter, err error) {
+	var notFound *mpioperatortracker.JobNotFoundError
+	if errors.As(err, &notFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	status := http.StatusInternalServerError
+	switch mpioperatortracker.ErrorID(err) {
+	case drmaa2interface.InvalidArgument, drmaa2interface.OutOfResource:
+		status = http.StatusBadRequest
+	case drmaa2interface.InvalidState:
+		status = http.StatusConflict
+	case drmaa2interface.UnsupportedOperation:
+		status = http.StatusNotImplemented
+	case drmaa2interface.DeniedByDrms:
+		status = http.StatusForbidden
+	case drmaa2interface.TryLater:
+		status = http.StatusServiceUnavailable
+	}
+	writeError(w, status, err)
+}
@@ -0,0 +1,310 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/mpioperatortracker"
+	"github.com/dgruber/mpioperatortracker/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+)
+
+// fakeTracker keeps jobs in memory. Each JobState call of a job returns
+// the next of its states until the last one is reached.
+type fakeTracker struct {
+	sync.Mutex
+	submitted []drmaa2interface.JobTemplate
+	states    map[string][]drmaa2interface.JobState
+	listed    int
+	jobInfos  int
+}
+
+func (f *fakeTracker) AddJob(jt drmaa2interface.JobTemplate) (string, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.submitted = append(f.submitted, jt)
+	f.states["job-1"] = []drmaa2interface.JobState{drmaa2interface.Queued}
+	return "job-1", nil
+}
+
+func (f *fakeTracker) JobState(jobID string) (drmaa2interface.JobState, string, error) {
+	f.Lock()
+	defer f.Unlock()
+	states, exists := f.states[jobID]
+	if !exists {
+		return drmaa2interface.Undetermined, "", &mpioperatortracker.JobNotFoundError{JobID: jobID}
+	}
+	if len(states) > 1 {
+		f.states[jobID] = states[1:]
+	}
+	return states[0], "", nil
+}
+
+func (f *fakeTracker) JobInfo(jobID string) (drmaa2interface.JobInfo, error) {
+	ji := drmaa2interface.CreateJobInfo()
+	ji.ID = jobID
+	f.Lock()
+	states, exists := f.states[jobID]
+	f.jobInfos++
+	f.Unlock()
+	if !exists {
+		return ji, &mpioperatortracker.JobNotFoundError{JobID: jobID}
+	}
+	ji.State = states[0]
+	return ji, nil
+}
+
+func (f *fakeTracker) JobControl(jobID, action string) error {
+	switch action {
+	case "terminate":
+		return nil
+	case "suspend", "resume", "hold", "release":
+		return drmaa2interface.Error{ID: drmaa2interface.UnsupportedOperation, Message: "unsupported operation"}
+	}
+	return drmaa2interface.Error{ID: drmaa2interface.InvalidArgument, Message: "undefined job operation"}
+}
+
+func (f *fakeTracker) ListJobInfosFiltered(filter mpioperatortracker.ListJobsFilter) ([]drmaa2interface.JobInfo, string, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.listed++
+	jobInfos := []drmaa2interface.JobInfo{}
+	for jobID, states := range f.states {
+		ji := drmaa2interface.CreateJobInfo()
+		ji.ID = jobID
+		ji.State = states[0]
+		jobInfos = append(jobInfos, ji)
+	}
+	return jobInfos, "", nil
+}
+
+func (f *fakeTracker) DeleteJob(jobID string) error {
+	state, _, err := f.JobState(jobID)
+	if err != nil {
+		return err
+	}
+	if !mpioperatortracker.IsEndState(state) {
+		return &mpioperatortracker.JobNotInEndStateError{JobID: jobID, State: state}
+	}
+	return nil
+}
+
+var _ = Describe("Server", func() {
+
+	var tracker *fakeTracker
+	var httpServer *httptest.Server
+
+	request := func(method, path, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, httpServer.URL+path, strings.NewReader(body))
+		Expect(err).To(BeNil())
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		Expect(err).To(BeNil())
+		return resp, string(content)
+	}
+
+	BeforeEach(func() {
+		tracker = &fakeTracker{states: map[string][]drmaa2interface.JobState{
+			"running": {drmaa2interface.Running},
+			"finishing": {drmaa2interface.Running, drmaa2interface.Running,
+				drmaa2interface.Done},
+		}}
+		httpServer = httptest.NewServer(server.New(tracker, server.Options{
+			Token:        "secret",
+			PollInterval: 10 * time.Millisecond,
+		}))
+	})
+
+	AfterEach(func() {
+		httpServer.Close()
+	})
+
+	It("should submit jobs with extensions", func() {
+		resp, body := request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpioperator/mpi-pi:openmpi", "minSlots": 4,
+			  "extensions": {"mpiImplementation": "OpenMPI"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		Expect(resp.Header.Get("Location")).To(Equal("/v1/jobs/job-1"))
+		Expect(body).To(ContainSubstring(`"id":"job-1"`))
+		Expect(tracker.submitted).To(HaveLen(1))
+		Expect(tracker.submitted[0].MinSlots).To(BeNumerically("==", 4))
+		Expect(tracker.submitted[0].ExtensionList).To(HaveKeyWithValue("mpiImplementation", "OpenMPI"))
+
+		resp, body = request(http.MethodGet, "/v1/jobs/job-1", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var info server.JobInfo
+		Expect(json.Unmarshal([]byte(body), &info)).To(Succeed())
+		Expect(info.State).To(Equal("Queued"))
+
+		resp, body = request(http.MethodGet, "/v1/jobs", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var list server.JobList
+		Expect(json.Unmarshal([]byte(body), &list)).To(Succeed())
+		Expect(list.Jobs).To(HaveLen(3))
+	})
+
+	It("should list the jobs with one list request", func() {
+		resp, body := request(http.MethodGet, "/v1/jobs", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var list server.JobList
+		Expect(json.Unmarshal([]byte(body), &list)).To(Succeed())
+		Expect(list.Jobs).To(HaveLen(2))
+		Expect(list.Jobs[0].ID).To(Equal("finishing"))
+		Expect(list.Jobs[1].ID).To(Equal("running"))
+		Expect(list.Jobs[1].State).To(Equal("Running"))
+		tracker.Lock()
+		defer tracker.Unlock()
+		Expect(tracker.listed).To(Equal(1))
+		Expect(tracker.jobInfos).To(BeZero())
+	})
+
+	It("should require the bearer token except for the specification", func() {
+		resp, err := http.Get(httpServer.URL + "/v1/jobs")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+		resp, err = http.Get(httpServer.URL + "/openapi.yaml")
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		spec, err := io.ReadAll(resp.Body)
+		Expect(err).To(BeNil())
+		var parsed map[string]interface{}
+		Expect(yaml.Unmarshal(spec, &parsed)).To(Succeed())
+		Expect(parsed).To(HaveKey("paths"))
+	})
+
+	It("should reject requests without a configured token unless insecure", func() {
+		closed := httptest.NewServer(server.New(tracker, server.Options{}))
+		defer closed.Close()
+		resp, err := http.Get(closed.URL + "/v1/jobs")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+
+		insecure := httptest.NewServer(server.New(tracker, server.Options{Insecure: true}))
+		defer insecure.Close()
+		resp, err = http.Get(insecure.URL + "/v1/jobs")
+		Expect(err).To(BeNil())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("should reject pod template patches unless the extensions are allowed", func() {
+		resp, body := request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2,
+			  "extensions": {"workerPodTemplatePatch": "spec: {hostNetwork: true}"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(body).To(ContainSubstring("workerPodTemplatePatch"))
+		Expect(tracker.submitted).To(BeEmpty())
+
+		httpServer.Close()
+		httpServer = httptest.NewServer(server.New(tracker, server.Options{
+			Token:             "secret",
+			AllowedExtensions: []string{"workerPodTemplatePatch", "resourceLimitWorker-*"},
+		}))
+		resp, _ = request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2,
+			  "extensions": {"workerPodTemplatePatch": "spec: {hostNetwork: true}",
+			                 "resourceLimitWorker-cpu": "2"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		resp, _ = request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2,
+			  "extensions": {"mpiImplementation": "OpenMPI"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(tracker.submitted).To(HaveLen(1))
+	})
+
+	It("should reject runAsUser and stageInFiles unless they are enabled", func() {
+		resp, body := request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2, "extensions": {"runAsUser": "0"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(body).To(ContainSubstring("runAsUser"))
+		resp, _ = request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2, "extensions": {"unknownExtension": "1"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		resp, body = request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2, "stageInFiles": {"/data": "pvc:data"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(body).To(ContainSubstring("stageInFiles"))
+		Expect(tracker.submitted).To(BeEmpty())
+
+		httpServer.Close()
+		httpServer = httptest.NewServer(server.New(tracker, server.Options{
+			Token: "secret",
+			AllowedExtensions: append(append([]string{}, server.DefaultAllowedExtensions...),
+				mpioperatortracker.ExtensionRunAsUser),
+			AllowStageInFiles: true,
+		}))
+		resp, _ = request(http.MethodPost, "/v1/jobs",
+			`{"jobCategory": "mpi-pi", "minSlots": 2, "stageInFiles": {"/data": "pvc:data"},
+			  "extensions": {"runAsUser": "1000", "resourceRequestWorker-cpu": "2"}}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		Expect(tracker.submitted).To(HaveLen(1))
+		Expect(tracker.submitted[0].StageInFiles).To(HaveKey("/data"))
+	})
+
+	It("should map tracker errors to HTTP status codes", func() {
+		resp, _ := request(http.MethodGet, "/v1/jobs/unknown/state", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		resp, body := request(http.MethodDelete, "/v1/jobs/running", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		Expect(body).To(ContainSubstring(`"error"`))
+		resp, _ = request(http.MethodPost, "/v1/jobs/running/control/suspend", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusNotImplemented))
+		resp, _ = request(http.MethodPost, "/v1/jobs/running/control/pause", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		resp, _ = request(http.MethodPost, "/v1/jobs/running/control/terminate", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		resp, _ = request(http.MethodPut, "/v1/jobs", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		resp, _ = request(http.MethodGet, "/v1/jobs?state=sleeping", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should long-poll until the job is finished or the timeout occurs", func() {
+		resp, body := request(http.MethodGet, "/v1/jobs/finishing/wait?timeout=5s", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		var result server.WaitResult
+		Expect(json.Unmarshal([]byte(body), &result)).To(Succeed())
+		Expect(result.Reached).To(BeTrue())
+		Expect(result.State).To(Equal("Done"))
+
+		resp, body = request(http.MethodGet, "/v1/jobs/running/wait?timeout=50ms", "")
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(json.Unmarshal([]byte(body), &result)).To(Succeed())
+		Expect(result.Reached).To(BeFalse())
+		Expect(result.State).To(Equal("Running"))
+	})
+
+	It("should send state changes as server-sent events", func() {
+		req, err := http.NewRequest(http.MethodGet,
+			httpServer.URL+"/v1/jobs/finishing/wait?state=done", nil)
+		Expect(err).To(BeNil())
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		events, err := io.ReadAll(resp.Body)
+		Expect(err).To(BeNil())
+		Expect(string(events)).To(Equal(
+			"event: state\ndata: {\"id\":\"finishing\",\"state\":\"Running\"}\n\n" +
+				"event: state\ndata: {\"id\":\"finishing\",\"state\":\"Done\"}\n\n" +
+				"event: done\ndata: {\"id\":\"finishing\",\"state\":\"Done\",\"reached\":true}\n\n"))
+	})
+
+})
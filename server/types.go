@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+)
+
+// JobTemplate is the request body for submitting a job: the JSON fields
+// of the DRMAA2 job template and its extensions (like mpiImplementation),
+// which are not part of the JSON representation of the job template.
+type JobTemplate struct {
+	drmaa2interface.JobTemplate
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+// JobState is the state of a job.
+type JobState struct {
+	ID       string `json:"id"`
+	State    string `json:"state,omitempty"`
+	SubState string `json:"subState,omitempty"`
+}
+
+// WaitResult is the response of a wait request. Reached is false when
+// the timeout occurred before the job was in one of the states.
+type WaitResult struct {
+	JobState
+	Reached bool `json:"reached"`
+}
+
+// JobInfo is the JSON representation of a DRMAA2 job info. The state is
+// the state name and unset values are omitted.
+type JobInfo struct {
+	ID                string     `json:"id"`
+	State             string     `json:"state"`
+	SubState          string     `json:"subState,omitempty"`
+	ExitStatus        *int       `json:"exitStatus,omitempty"`
+	Slots             int64      `json:"slots,omitempty"`
+	QueueName         string     `json:"queueName,omitempty"`
+	JobOwner          string     `json:"jobOwner,omitempty"`
+	AllocatedMachines []string   `json:"allocatedMachines,omitempty"`
+	SubmissionTime    *time.Time `json:"submissionTime,omitempty"`
+	DispatchTime      *time.Time `json:"dispatchTime,omitempty"`
+	FinishTime        *time.Time `json:"finishTime,omitempty"`
+	// WallclockTime is the runtime in seconds.
+	WallclockTime float64 `json:"wallclockTime,omitempty"`
+}
+
+// JobList is one page of jobs. Continue is the token for the next page.
+type JobList struct {
+	Jobs     []JobInfo `json:"jobs"`
+	Continue string    `json:"continue,omitempty"`
+}
+
+// Error is the response body of failed requests.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// NewJobInfo converts a DRMAA2 job info.
+func NewJobInfo(ji drmaa2interface.JobInfo) JobInfo {
+	info := JobInfo{
+		ID:                ji.ID,
+		State:             ji.State.String(),
+		SubState:          ji.SubState,
+		QueueName:         ji.QueueName,
+		JobOwner:          ji.JobOwner,
+		AllocatedMachines: ji.AllocatedMachines,
+		SubmissionTime:    timeOrNil(ji.SubmissionTime),
+		DispatchTime:      timeOrNil(ji.DispatchTime),
+		FinishTime:        timeOrNil(ji.FinishTime),
+		WallclockTime:     ji.WallclockTime.Seconds(),
+	}
+	if ji.ExitStatus != drmaa2interface.UnsetNum {
+		exitStatus := ji.ExitStatus
+		info.ExitStatus = &exitStatus
+	}
+	if ji.Slots > 0 {
+		info.Slots = ji.Slots
+	}
+	return info
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// ParseJobState returns the DRMAA2 job state of the name (like "running"
+// or "Done").
+func ParseJobState(name string) (drmaa2interface.JobState, error) {
+	for _, state := range []drmaa2interface.JobState{
+		drmaa2interface.Undetermined, drmaa2interface.Queued, drmaa2interface.QueuedHeld,
+		drmaa2interface.Running, drmaa2interface.Suspended, drmaa2interface.Requeued,
+		drmaa2interface.RequeuedHeld, drmaa2interface.Done, drmaa2interface.Failed,
+	} {
+		if strings.EqualFold(state.String(), name) {
+			return state, nil
+		}
+	}
+	return drmaa2interface.Unset, fmt.Errorf("unknown job state %q", name)
+}
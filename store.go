@@ -0,0 +1,390 @@
+package mpioperatortracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	"github.com/dgruber/drmaa2os/pkg/jobtracker"
+	bolt "go.etcd.io/bbolt"
+)
+
+var jobsBucket = []byte("jobs")
+
+var arrayJobsBucket = []byte("arrayjobs")
+
+var _ jobtracker.JobTemplater = &MPIOperatorTracker{}
+var _ jobtracker.Closer = &MPIOperatorTracker{}
+
+// JobRecord is the information the JobStore keeps about a submitted job.
+type JobRecord struct {
+	JobID          string `json:"jobID"`
+	Namespace      string `json:"namespace"`
+	JobSessionName string `json:"jobSessionName"`
+	// JobTemplate is the job template the job was submitted with.
+	JobTemplate drmaa2interface.JobTemplate `json:"jobTemplate"`
+	// Extensions is the ExtensionList of the job template which is not
+	// part of its JSON representation.
+	Extensions map[string]string `json:"extensions,omitempty"`
+	// ArrayJobID and TaskID are set when the job is a task of an
+	// array job.
+	ArrayJobID string `json:"arrayJobID,omitempty"`
+	TaskID     int    `json:"taskID,omitempty"`
+	// JobInfo is the last job info of the job which is stored when
+	// the job reached an end state.
+	JobInfo *drmaa2interface.JobInfo `json:"jobInfo,omitempty"`
+}
+
+// Template returns the job template including its extensions.
+func (r JobRecord) Template() drmaa2interface.JobTemplate {
+	jt := r.JobTemplate
+	jt.ExtensionList = r.Extensions
+	return jt
+}
+
+// ArrayJobRecord is the information the JobStore keeps about an array job
+// whose tasks are not all submitted yet (see AddArrayJob with maxParallel).
+type ArrayJobRecord struct {
+	ArrayJobID     string                      `json:"arrayJobID"`
+	Namespace      string                      `json:"namespace"`
+	JobSessionName string                      `json:"jobSessionName"`
+	JobTemplate    drmaa2interface.JobTemplate `json:"jobTemplate"`
+	Extensions     map[string]string           `json:"extensions,omitempty"`
+	Begin          int                         `json:"begin"`
+	End            int                         `json:"end"`
+	Step           int                         `json:"step"`
+	MaxParallel    int                         `json:"maxParallel"`
+	// PendingTaskIDs are the IDs of the tasks which are not yet
+	// submitted.
+	PendingTaskIDs []int `json:"pendingTaskIDs"`
+}
+
+// Template returns the job template including its extensions.
+func (r ArrayJobRecord) Template() drmaa2interface.JobTemplate {
+	jt := r.JobTemplate
+	jt.ExtensionList = r.Extensions
+	return jt
+}
+
+// JobStore is a local bbolt database which records all jobs submitted
+// by the tracker so that job sessions can be reopened after a restart
+// and finished jobs can be queried after the MPIJob is removed.
+type JobStore struct {
+	db   *bolt.DB
+	path string
+	refs int
+}
+
+// open job stores by path; bbolt allows only one open handle per file
+// hence trackers of the same process share the store
+var jobStores = struct {
+	sync.Mutex
+	stores map[string]*JobStore
+}{stores: make(map[string]*JobStore)}
+
+// OpenJobStore opens or creates the job store at the given path. When
+// the store is already opened in the process the same instance is
+// returned. Each OpenJobStore call requires a Close call.
+func OpenJobStore(path string) (*JobStore, error) {
+	jobStores.Lock()
+	defer jobStores.Unlock()
+	if store, exists := jobStores.stores[path]; exists {
+		store.refs++
+		return store, nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{jobsBucket, arrayJobsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	store := &JobStore{db: db, path: path, refs: 1}
+	jobStores.stores[path] = store
+	return store, nil
+}
+
+// Close closes the underlying database when it is not used anymore.
+func (s *JobStore) Close() error {
+	jobStores.Lock()
+	defer jobStores.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	delete(jobStores.stores, s.path)
+	return s.db.Close()
+}
+
+func jobKey(namespace, jobID string) []byte {
+	return []byte(namespace + "/" + jobID)
+}
+
+// Put creates or replaces the record of a job.
+func (s *JobStore) Put(record JobRecord) error {
+	if record.Extensions == nil {
+		record.Extensions = record.JobTemplate.ExtensionList
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Put(jobKey(record.Namespace, record.JobID), value)
+	})
+}
+
+// Get returns the record of a job. If the job is not found an error
+// is returned.
+func (s *JobStore) Get(namespace, jobID string) (JobRecord, error) {
+	var record JobRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(jobsBucket).Get(jobKey(namespace, jobID))
+		if value == nil {
+			return fmt.Errorf("job %s not found in job store", jobID)
+		}
+		return json.Unmarshal(value, &record)
+	})
+	return record, err
+}
+
+// SetJobInfo stores the final job info of a job.
+func (s *JobStore) SetJobInfo(namespace, jobID string, jobInfo drmaa2interface.JobInfo) error {
+	record, err := s.Get(namespace, jobID)
+	if err != nil {
+		return err
+	}
+	record.JobInfo = &jobInfo
+	return s.Put(record)
+}
+
+// Delete removes the record of a job.
+func (s *JobStore) Delete(namespace, jobID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Delete(jobKey(namespace, jobID))
+	})
+}
+
+// List returns all records of the namespace which belong to the job
+// session. An empty job session name returns the records of all job
+// sessions.
+func (s *JobStore) List(namespace, jobSessionName string) ([]JobRecord, error) {
+	var records []JobRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+			var record JobRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.Namespace != namespace {
+				return nil
+			}
+			if jobSessionName != "" && record.JobSessionName != jobSessionName {
+				return nil
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// PutArrayJob creates or replaces the record of an array job.
+func (s *JobStore) PutArrayJob(record ArrayJobRecord) error {
+	if record.Extensions == nil {
+		record.Extensions = record.JobTemplate.ExtensionList
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(arrayJobsBucket).Put(jobKey(record.Namespace, record.ArrayJobID), value)
+	})
+}
+
+// GetArrayJob returns the record of an array job. If the array job is not
+// found an error is returned.
+func (s *JobStore) GetArrayJob(namespace, arrayJobID string) (ArrayJobRecord, error) {
+	var record ArrayJobRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(arrayJobsBucket).Get(jobKey(namespace, arrayJobID))
+		if value == nil {
+			return fmt.Errorf("array job %s not found in job store", arrayJobID)
+		}
+		return json.Unmarshal(value, &record)
+	})
+	return record, err
+}
+
+// DeleteArrayJob removes the record of an array job.
+func (s *JobStore) DeleteArrayJob(namespace, arrayJobID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(arrayJobsBucket).Delete(jobKey(namespace, arrayJobID))
+	})
+}
+
+// ListArrayJobs returns all array job records of the namespace which
+// belong to the job session. An empty job session name returns the
+// records of all job sessions.
+func (s *JobStore) ListArrayJobs(namespace, jobSessionName string) ([]ArrayJobRecord, error) {
+	var records []ArrayJobRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(arrayJobsBucket).ForEach(func(k, v []byte) error {
+			var record ArrayJobRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.Namespace != namespace {
+				return nil
+			}
+			if jobSessionName != "" && record.JobSessionName != jobSessionName {
+				return nil
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// recordJob adds a newly submitted job to the job store of the tracker.
+func (t *MPIOperatorTracker) recordJob(jobID string, jt drmaa2interface.JobTemplate, arrayJobID string, taskID int) error {
+	if t.store == nil {
+		return nil
+	}
+	err := t.store.Put(JobRecord{
+		JobID:          jobID,
+		Namespace:      t.Namespace(),
+		JobSessionName: t.jobSessionName,
+		JobTemplate:    jt,
+		ArrayJobID:     arrayJobID,
+		TaskID:         taskID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record job %s in job store: %v", jobID, err)
+	}
+	return nil
+}
+
+// recordJobInfo stores the job info of a finished job once.
+func (t *MPIOperatorTracker) recordJobInfo(jobInfo drmaa2interface.JobInfo) {
+	if t.store == nil || !IsEndState(jobInfo.State) {
+		return
+	}
+	record, err := t.store.Get(t.Namespace(), jobInfo.ID)
+	if err != nil {
+		// not submitted by a tracker using the store
+		return
+	}
+	if record.JobInfo != nil && record.JobInfo.State == jobInfo.State &&
+		record.JobInfo.SubState == jobInfo.SubState {
+		return
+	}
+	record.JobInfo = &jobInfo
+	if err := t.store.Put(record); err != nil {
+		t.jobLogger(jobInfo.ID, "store").Error(err, "failed to store job info")
+	}
+}
+
+// SubStateRemoved is the sub state of a job in the job store whose
+// MPIJob was removed from the cluster before the tracker has seen its end
+// state. The state of such a job is Undetermined.
+const SubStateRemoved = "removed"
+
+// storedJobInfo returns the job info of a job which is no longer in the
+// cluster from the store. Jobs whose end state was not recorded are
+// Undetermined.
+func (t *MPIOperatorTracker) storedJobInfo(jobID string) (drmaa2interface.JobInfo, bool) {
+	if t.store == nil {
+		return drmaa2interface.JobInfo{}, false
+	}
+	record, err := t.store.Get(t.Namespace(), jobID)
+	if err != nil {
+		return drmaa2interface.JobInfo{}, false
+	}
+	if record.JobInfo == nil {
+		jobInfo := drmaa2interface.CreateJobInfo()
+		jobInfo.ID = jobID
+		jobInfo.State = drmaa2interface.Undetermined
+		jobInfo.SubState = SubStateRemoved
+		return jobInfo, true
+	}
+	return *record.JobInfo, true
+}
+
+// storedArrayTasks returns the tasks of an array job known by the store.
+func (t *MPIOperatorTracker) storedArrayTasks(arrayJobID string) ([]ArrayTask, error) {
+	if t.store == nil {
+		return nil, nil
+	}
+	records, err := t.store.List(t.Namespace(), "")
+	if err != nil {
+		return nil, err
+	}
+	var tasks []ArrayTask
+	for _, record := range records {
+		if record.ArrayJobID != arrayJobID {
+			continue
+		}
+		task := ArrayTask{
+			JobID:  record.JobID,
+			TaskID: record.TaskID,
+			State:  drmaa2interface.Undetermined,
+		}
+		if record.JobInfo != nil {
+			task.State = record.JobInfo.State
+			task.SubState = record.JobInfo.SubState
+		}
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].TaskID < tasks[j].TaskID
+	})
+	return tasks, nil
+}
+
+// JobTemplate returns the job template of a job which was submitted
+// by a tracker using a job store.
+func (t *MPIOperatorTracker) JobTemplate(jobID string) (drmaa2interface.JobTemplate, error) {
+	if t.store == nil {
+		return drmaa2interface.JobTemplate{}, fmt.Errorf("job templates are only available with a job store")
+	}
+	record, err := t.store.Get(t.Namespace(), jobID)
+	if err != nil {
+		return drmaa2interface.JobTemplate{}, err
+	}
+	return record.Template(), nil
+}
+
+// Close stops the background submission of array job tasks, closes the
+// job store of the tracker, and stops observing the jobs of its namespace
+// for the metrics. Tasks which are not yet submitted can be resumed with
+// ResumeArrayJobs by a tracker using the same job store.
+func (t *MPIOperatorTracker) Close() error {
+	t.backgroundContext()
+	t.cancel()
+	t.background.Wait()
+	if t.metrics != nil {
+		t.metrics.removeRefresher(t)
+		t.metrics = nil
+	}
+	if t.store == nil {
+		return nil
+	}
+	err := t.store.Close()
+	t.store = nil
+	return err
+}
@@ -0,0 +1,160 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Job store", func() {
+
+	var tempDir string
+	var tracker *MPIOperatorTracker
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "jobstore")
+		Expect(err).To(BeNil())
+		store, err := OpenJobStore(filepath.Join(tempDir, "jobs.db"))
+		Expect(err).To(BeNil())
+		clientset := fake.NewSimpleClientset()
+		// the fake clientset does not generate names
+		generated := 0
+		clientset.PrependReactor("create", "mpijobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			job := action.(k8stesting.CreateAction).GetObject().(*kubeflow.MPIJob)
+			if job.Name == "" {
+				generated++
+				job.Name = fmt.Sprintf("%s%d", job.GenerateName, generated)
+			}
+			return false, nil, nil
+		})
+		tracker = &MPIOperatorTracker{
+			clientset:      clientset,
+			kubeClient:     k8sfake.NewSimpleClientset(),
+			jobSessionName: "session",
+			store:          store,
+		}
+	})
+
+	AfterEach(func() {
+		Expect(tracker.Close()).To(BeNil())
+		os.RemoveAll(tempDir)
+	})
+
+	It("should share an opened store within the process", func() {
+		store, err := OpenJobStore(filepath.Join(tempDir, "jobs.db"))
+		Expect(err).To(BeNil())
+		Expect(store).To(BeIdenticalTo(tracker.store))
+		Expect(store.Close()).To(BeNil())
+	})
+
+	It("should keep finished jobs queryable after removal from the cluster", func() {
+		jt := drmaa2interface.JobTemplate{
+			JobName:     "stored",
+			JobCategory: "mpioperator/mpi-pi:intel",
+			MinSlots:    2,
+			Extension:   drmaa2interface.Extension{ExtensionList: map[string]string{"key": "value"}},
+		}
+		jobID, err := tracker.AddJob(jt)
+		Expect(err).To(BeNil())
+
+		template, err := tracker.JobTemplate(jobID)
+		Expect(err).To(BeNil())
+		Expect(template.JobCategory).To(Equal(jt.JobCategory))
+		Expect(template.ExtensionList).To(Equal(jt.ExtensionList))
+
+		job, err := DescribeJob(context.Background(), tracker.clientset, "default", jobID)
+		Expect(err).To(BeNil())
+		job.Status.Conditions = append(job.Status.Conditions,
+			common.JobCondition{Type: common.JobSucceeded})
+		_, err = tracker.clientset.KubeflowV2beta1().MPIJobs("default").UpdateStatus(
+			context.Background(), job, metav1.UpdateOptions{})
+		Expect(err).To(BeNil())
+
+		state, _, err := tracker.JobState(jobID)
+		Expect(err).To(BeNil())
+		Expect(state).To(Equal(drmaa2interface.Done))
+
+		// removed outside of the tracker
+		err = DeleteJob(context.Background(), tracker.clientset, "default", jobID)
+		Expect(err).To(BeNil())
+
+		jobInfo, err := tracker.JobInfo(jobID)
+		Expect(err).To(BeNil())
+		Expect(jobInfo.State).To(Equal(drmaa2interface.Done))
+
+		jobIDs, err := tracker.ListJobs()
+		Expect(err).To(BeNil())
+		Expect(jobIDs).To(ContainElement(jobID))
+
+		err = tracker.DeleteJob(jobID)
+		Expect(err).To(BeNil())
+		jobIDs, err = tracker.ListJobs()
+		Expect(err).To(BeNil())
+		Expect(jobIDs).NotTo(ContainElement(jobID))
+		_, err = tracker.JobInfo(jobID)
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should record the end state seen while waiting without a job info call", func() {
+		jobID, err := tracker.AddJob(drmaa2interface.JobTemplate{
+			JobCategory: "mpioperator/mpi-pi:intel",
+			MinSlots:    2,
+		})
+		Expect(err).To(BeNil())
+		job, err := DescribeJob(context.Background(), tracker.clientset, "default", jobID)
+		Expect(err).To(BeNil())
+		job.Status.Conditions = append(job.Status.Conditions,
+			common.JobCondition{Type: common.JobFailed})
+		_, err = tracker.clientset.KubeflowV2beta1().MPIJobs("default").UpdateStatus(
+			context.Background(), job, metav1.UpdateOptions{})
+		Expect(err).To(BeNil())
+
+		Expect(jobID).To(HavePrefix("drmaa2-mpioperator-job-"))
+		Expect(tracker.Wait(jobID, time.Second, drmaa2interface.Failed)).To(Succeed())
+		Expect(DeleteJob(context.Background(), tracker.clientset, "default", jobID)).To(Succeed())
+
+		jobInfo, err := tracker.JobInfo(jobID)
+		Expect(err).To(BeNil())
+		Expect(jobInfo.State).To(Equal(drmaa2interface.Failed))
+	})
+
+	It("should report jobs removed before their end state was seen as undetermined", func() {
+		jobID, err := tracker.AddJob(drmaa2interface.JobTemplate{
+			JobCategory: "mpioperator/mpi-pi:intel",
+			MinSlots:    2,
+		})
+		Expect(err).To(BeNil())
+		// removed outside of the tracker without a prior job info call
+		Expect(DeleteJob(context.Background(), tracker.clientset, "default", jobID)).To(Succeed())
+
+		jobInfo, err := tracker.JobInfo(jobID)
+		Expect(err).To(BeNil())
+		Expect(jobInfo.ID).To(Equal(jobID))
+		Expect(jobInfo.State).To(Equal(drmaa2interface.Undetermined))
+		Expect(jobInfo.SubState).To(Equal(SubStateRemoved))
+		state, subState, err := tracker.JobState(jobID)
+		Expect(err).To(BeNil())
+		Expect(state).To(Equal(drmaa2interface.Undetermined))
+		Expect(subState).To(Equal(SubStateRemoved))
+
+		Expect(tracker.DeleteJob(jobID)).To(Succeed())
+		jobIDs, err := tracker.ListJobs()
+		Expect(err).To(BeNil())
+		Expect(jobIDs).NotTo(ContainElement(jobID))
+	})
+
+})
@@ -0,0 +1,163 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	kubeflow "github.com/kubeflow/mpi-operator/v2/pkg/apis/kubeflow/v2beta1"
+	clientset "github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// AnnotationTerminated is set on an MPIJob which was terminated through
+// JobControl. The value is the termination time in RFC3339 format.
+const AnnotationTerminated = "mpioperatortracker.drmaa2.io/terminated"
+
+// ConditionReasonTerminated is the reason of the failed condition which is
+// added to the job status on termination.
+const ConditionReasonTerminated = "MPIJobTerminated"
+
+// IsTerminated returns true if the MPIJob was terminated by the tracker.
+func IsTerminated(mpiJob *kubeflow.MPIJob) bool {
+	if mpiJob.Annotations == nil {
+		return false
+	}
+	_, exists := mpiJob.Annotations[AnnotationTerminated]
+	return exists
+}
+
+// LauncherJobName returns the name of the batch Job which the operator
+// creates for the launcher of the MPIJob.
+func LauncherJobName(jobName string) string {
+	return jobName + "-launcher"
+}
+
+// TerminateJob stops a job but keeps the MPIJob object so that its state
+// and job info can still be queried. First a failed condition with a
+// completion time is added to the job status, so that the operator treats
+// the job as finished and does not recreate its resources. Then the
+// launcher Job is deleted, otherwise the Job controller would restart the
+// launcher pod, and the launcher and worker pods are removed. Finally the
+// job gets annotated as terminated. Each step can be repeated, hence a
+// TerminateJob call which failed part way can be retried. Without
+// kubeClient only the status is changed and the launcher keeps running.
+func TerminateJob(ctx context.Context, mpiClient clientset.Interface, kubeClient kubernetes.Interface, namespace, jobName string) error {
+	job, err := DescribeJob(ctx, mpiClient, namespace, jobName)
+	if apierrors.IsNotFound(err) {
+		return &JobNotFoundError{JobID: jobName}
+	}
+	if err != nil {
+		return err
+	}
+	if IsTerminated(job) {
+		return nil
+	}
+	if isFinished(job) && !hasTerminatedCondition(job) {
+		return newError(drmaa2interface.InvalidState, "job %s is already finished", jobName)
+	}
+
+	now := metav1.Now()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		job, err := DescribeJob(ctx, mpiClient, namespace, jobName)
+		if err != nil {
+			return err
+		}
+		if hasTerminatedCondition(job) {
+			return nil
+		}
+		job.Status.Conditions = append(job.Status.Conditions, common.JobCondition{
+			Type:               common.JobFailed,
+			Status:             v1.ConditionTrue,
+			Reason:             ConditionReasonTerminated,
+			Message:            fmt.Sprintf("MPIJob %s/%s is terminated.", namespace, jobName),
+			LastUpdateTime:     now,
+			LastTransitionTime: now,
+		})
+		job.Status.CompletionTime = &now
+		if job.Status.StartTime == nil {
+			job.Status.StartTime = &now
+		}
+		_, err = mpiClient.KubeflowV2beta1().MPIJobs(namespace).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+
+	if kubeClient != nil {
+		if err := deleteLauncherAndPods(ctx, kubeClient, namespace, jobName); err != nil {
+			return err
+		}
+	}
+
+	// the annotation is written last as terminated jobs are not touched again
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		job, err := DescribeJob(ctx, mpiClient, namespace, jobName)
+		if err != nil {
+			return err
+		}
+		if job.Annotations == nil {
+			job.Annotations = make(map[string]string)
+		}
+		job.Annotations[AnnotationTerminated] = now.Format(time.RFC3339)
+		_, err = mpiClient.KubeflowV2beta1().MPIJobs(namespace).Update(ctx, job, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to annotate job as terminated: %w", err)
+	}
+	return nil
+}
+
+// deleteLauncherAndPods deletes the launcher Job and the pods of the job.
+func deleteLauncherAndPods(ctx context.Context, kubeClient kubernetes.Interface, namespace, jobName string) error {
+	background := metav1.DeletePropagationBackground
+	err := kubeClient.BatchV1().Jobs(namespace).Delete(ctx, LauncherJobName(jobName),
+		metav1.DeleteOptions{PropagationPolicy: &background})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete launcher job: %w", err)
+	}
+	selector := labels.SelectorFromSet(labels.Set{common.JobNameLabel: jobName}).String()
+	pods, err := kubeClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("failed to list pods of job: %w", err)
+	}
+	for _, pod := range pods.Items {
+		err := kubeClient.CoreV1().Pods(namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete pod %s of job: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
+
+// hasTerminatedCondition returns true if the failed condition of a
+// termination was added to the job status.
+func hasTerminatedCondition(mpiJob *kubeflow.MPIJob) bool {
+	for _, condition := range mpiJob.Status.Conditions {
+		if condition.Type == common.JobFailed && condition.Reason == ConditionReasonTerminated {
+			return true
+		}
+	}
+	return false
+}
+
+func isFinished(mpiJob *kubeflow.MPIJob) bool {
+	for _, condition := range mpiJob.Status.Conditions {
+		if condition.Status == v1.ConditionFalse {
+			continue
+		}
+		if condition.Type == common.JobSucceeded || condition.Type == common.JobFailed {
+			return true
+		}
+	}
+	return false
+}
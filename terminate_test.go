@@ -0,0 +1,127 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgruber/drmaa2interface"
+	common "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/mpi-operator/v2/pkg/client/clientset/versioned/fake"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Terminate", func() {
+
+	var tracker *MPIOperatorTracker
+
+	BeforeEach(func() {
+		tracker = &MPIOperatorTracker{
+			clientset: fake.NewSimpleClientset(
+				newFakeMPIJob("running", common.JobCreated, common.JobRunning),
+				newFakeMPIJob("succeeded", common.JobCreated, common.JobRunning, common.JobSucceeded),
+			),
+			kubeClient: k8sfake.NewSimpleClientset(
+				&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+					Name:      LauncherJobName("running"),
+					Namespace: "default",
+				}},
+				newFakeLauncherPod("running-launcher-abcde", "running", time.Now()),
+			),
+		}
+	})
+
+	It("should delete the launcher job and the pods", func() {
+		err := tracker.JobControl("running", "terminate")
+		Expect(err).To(BeNil())
+
+		_, err = tracker.kubeClient.BatchV1().Jobs("default").Get(context.Background(),
+			LauncherJobName("running"), metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		pods, err := tracker.kubeClient.CoreV1().Pods("default").List(context.Background(),
+			metav1.ListOptions{})
+		Expect(err).To(BeNil())
+		Expect(pods.Items).To(BeEmpty())
+
+		job, err := DescribeJob(context.Background(), tracker.clientset, "default", "running")
+		Expect(err).To(BeNil())
+		Expect(job.Status.CompletionTime).NotTo(BeNil())
+	})
+
+	It("should keep the job record after termination", func() {
+		err := tracker.JobControl("running", "terminate")
+		Expect(err).To(BeNil())
+
+		state, subState, err := tracker.JobState("running")
+		Expect(err).To(BeNil())
+		Expect(state).To(Equal(drmaa2interface.Failed))
+		Expect(subState).To(Equal("terminated"))
+
+		jobInfo, err := tracker.JobInfo("running")
+		Expect(err).To(BeNil())
+		Expect(jobInfo.ID).To(Equal("running"))
+		Expect(jobInfo.State).To(Equal(drmaa2interface.Failed))
+		Expect(jobInfo.FinishTime.IsZero()).To(BeFalse())
+
+		// terminating twice is fine
+		err = tracker.JobControl("running", "terminate")
+		Expect(err).To(BeNil())
+
+		// terminated jobs are in an end state and can be deleted
+		err = tracker.DeleteJob("running")
+		Expect(err).To(BeNil())
+		_, _, err = tracker.JobState("running")
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should finish a termination which failed part way when retried", func() {
+		mpiClient := tracker.clientset.(*fake.Clientset)
+		mpiClient.PrependReactor("update", "mpijobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "status" {
+				return false, nil, nil
+			}
+			mpiClient.ReactionChain = mpiClient.ReactionChain[1:]
+			return true, nil, errors.New("status update failed")
+		})
+		err := tracker.JobControl("running", "terminate")
+		Expect(err).NotTo(BeNil())
+		job, err := DescribeJob(context.Background(), tracker.clientset, "default", "running")
+		Expect(err).To(BeNil())
+		Expect(IsTerminated(job)).To(BeFalse())
+
+		kubeClient := tracker.kubeClient.(*k8sfake.Clientset)
+		kubeClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			kubeClient.ReactionChain = kubeClient.ReactionChain[1:]
+			return true, nil, errors.New("pod deletion failed")
+		})
+		err = tracker.JobControl("running", "terminate")
+		Expect(err).NotTo(BeNil())
+		job, err = DescribeJob(context.Background(), tracker.clientset, "default", "running")
+		Expect(err).To(BeNil())
+		Expect(IsTerminated(job)).To(BeFalse())
+
+		err = tracker.JobControl("running", "terminate")
+		Expect(err).To(BeNil())
+		pods, err := tracker.kubeClient.CoreV1().Pods("default").List(context.Background(),
+			metav1.ListOptions{})
+		Expect(err).To(BeNil())
+		Expect(pods.Items).To(BeEmpty())
+		state, subState, err := tracker.JobState("running")
+		Expect(err).To(BeNil())
+		Expect(state).To(Equal(drmaa2interface.Failed))
+		Expect(subState).To(Equal("terminated"))
+	})
+
+	It("should not terminate a finished job", func() {
+		err := tracker.JobControl("succeeded", "terminate")
+		Expect(err).NotTo(BeNil())
+	})
+
+})
@@ -0,0 +1,192 @@
+package mpioperatortracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// MPIJobsExistError is returned when the MPI operator should be removed
+// while MPIJobs still exist.
+type MPIJobsExistError struct {
+	// Jobs contains namespace/name of (some of) the existing MPIJobs.
+	Jobs []string
+}
+
+func (e *MPIJobsExistError) Error() string {
+	return fmt.Sprintf("MPIJobs still exist (%s); remove them or force the uninstallation",
+		strings.Join(e.Jobs, ", "))
+}
+
+// UninstallMPIOperator removes all objects of the embedded MPI operator
+// manifest from the cluster defined by the kubeconfig. It refuses to
+// remove the operator while MPIJobs exist unless force is set, as
+// removing the CRD deletes all MPIJobs.
+func UninstallMPIOperator(kubeconfigPath string, force bool) error {
+	restConfig, err := NewRestConfig(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to create REST config: %v", err)
+	}
+	_, err = UninstallMPIOperatorWithConfig(context.Background(), restConfig, force, InstallOptions{})
+	return err
+}
+
+// UninstallMPIOperatorWithConfig removes all objects of the MPI operator
+// manifest and returns the result for each object. The options must be
+// the ones used for the installation, so that the same objects are
+// removed.
+func UninstallMPIOperatorWithConfig(ctx context.Context, restConfig *rest.Config, force bool, opts InstallOptions) ([]ApplyResult, error) {
+	objects, err := ManifestObjects(opts)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, mapper, err := newDynamicClientAndMapper(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !force {
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discovery client: %v", err)
+		}
+		if err := CheckNoMPIJobsExist(ctx, discoveryClient, dynamicClient); err != nil {
+			return nil, err
+		}
+	}
+	results, err := DeleteObjects(ctx, dynamicClient, mapper, objects)
+	if err != nil {
+		return results, fmt.Errorf("failed to uninstall MPI Operator: %v", err)
+	}
+	return results, nil
+}
+
+// UpgradeMPIOperator updates the MPI operator in the cluster defined by
+// the kubeconfig to the embedded manifest.
+func UpgradeMPIOperator(kubeconfigPath string) error {
+	restConfig, err := NewRestConfig(kubeconfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to create REST config: %v", err)
+	}
+	_, err = UpgradeMPIOperatorWithConfig(context.Background(), restConfig, InstallOptions{})
+	return err
+}
+
+// UpgradeMPIOperatorWithConfig updates the CRD, RBAC, namespace, and the
+// deployment of the MPI operator to the manifest of the options with
+// server-side apply. Fields owned by other managers (like kubectl) are
+// taken over. Changes of the deployment are rolled out by Kubernetes.
+// Existing MPIJobs are kept. Unless opts.SkipWait is set it waits until
+// the operator is ready.
+func UpgradeMPIOperatorWithConfig(ctx context.Context, restConfig *rest.Config, opts InstallOptions) ([]ApplyResult, error) {
+	objects, err := ManifestObjects(opts)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, mapper, err := newDynamicClientAndMapper(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	results, err := ApplyObjects(ctx, dynamicClient, mapper, objects)
+	if err != nil {
+		return results, fmt.Errorf("failed to upgrade MPI Operator: %v", err)
+	}
+	return results, waitForInstallation(ctx, restConfig, dynamicClient, opts)
+}
+
+// CheckNoMPIJobsExist returns an MPIJobsExistError if there are MPIJobs
+// in any namespace. The MPIJobs are listed in all served versions of the
+// kubeflow.org API group, so that jobs are found independent of the API
+// version the installed operator serves. When no version serves MPIJobs
+// (the MPIJob CRD is not installed) no error is returned.
+func CheckNoMPIJobsExist(ctx context.Context, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface) error {
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return fmt.Errorf("failed to discover API groups: %v", err)
+	}
+	for _, group := range groups.Groups {
+		if group.Name != MPIJobGroup {
+			continue
+		}
+		for _, version := range group.Versions {
+			resource := schema.GroupVersionResource{
+				Group:    MPIJobGroup,
+				Version:  version.Version,
+				Resource: "mpijobs",
+			}
+			jobs, err := dynamicClient.Resource(resource).Namespace(metav1.NamespaceAll).List(ctx,
+				metav1.ListOptions{Limit: 10})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					// the version does not serve MPIJobs
+					continue
+				}
+				return fmt.Errorf("failed to check for existing MPIJobs of %s: %v",
+					version.GroupVersion, err)
+			}
+			if len(jobs.Items) == 0 {
+				continue
+			}
+			existing := make([]string, 0, len(jobs.Items))
+			for _, job := range jobs.Items {
+				existing = append(existing, job.GetNamespace()+"/"+job.GetName())
+			}
+			return &MPIJobsExistError{Jobs: existing}
+		}
+	}
+	return nil
+}
+
+// DeleteManifest deletes all objects of the manifest in reverse order.
+// Objects which don't exist are reported as ActionNotFound. The returned
+// error lists the objects which failed to be deleted.
+func DeleteManifest(ctx context.Context, dynamicClient dynamic.Interface, mapper meta.RESTMapper, manifest []byte) ([]ApplyResult, error) {
+	objects, err := DecodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return DeleteObjects(ctx, dynamicClient, mapper, objects)
+}
+
+// DeleteObjects deletes the objects like DeleteManifest.
+func DeleteObjects(ctx context.Context, dynamicClient dynamic.Interface, mapper meta.RESTMapper, objects []*unstructured.Unstructured) ([]ApplyResult, error) {
+	results := make([]ApplyResult, 0, len(objects))
+	var failed []string
+	for i := len(objects) - 1; i >= 0; i-- {
+		object := objects[i]
+		result := ApplyResult{
+			Kind:      object.GetKind(),
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+			Action:    ActionDeleted,
+		}
+		resource, err := resourceInterface(dynamicClient, mapper, object)
+		if err == nil {
+			policy := metav1.DeletePropagationBackground
+			err = resource.Delete(ctx, object.GetName(),
+				metav1.DeleteOptions{PropagationPolicy: &policy})
+		}
+		if err != nil {
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				result.Action = ActionNotFound
+			} else {
+				result.Err = err
+				failed = append(failed, result.String())
+			}
+		}
+		results = append(results, result)
+	}
+	if len(failed) > 0 {
+		return results, errors.New(strings.Join(failed, "; "))
+	}
+	return results, nil
+}
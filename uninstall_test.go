@@ -0,0 +1,102 @@
+package mpioperatortracker
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Uninstall", func() {
+
+	newDiscovery := func(versions ...string) *fakediscovery.FakeDiscovery {
+		discoveryClient := k8sfake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+		for _, version := range versions {
+			discoveryClient.Resources = append(discoveryClient.Resources, &metav1.APIResourceList{
+				GroupVersion: "kubeflow.org/" + version,
+				APIResources: []metav1.APIResource{{Name: "mpijobs", Kind: "MPIJob", Namespaced: true}},
+			})
+		}
+		return discoveryClient
+	}
+
+	newMPIJobObject := func(version, name string) *unstructured.Unstructured {
+		job := &unstructured.Unstructured{}
+		job.SetAPIVersion("kubeflow.org/" + version)
+		job.SetKind("MPIJob")
+		job.SetNamespace("default")
+		job.SetName(name)
+		return job
+	}
+
+	It("should refuse to uninstall while MPIJobs exist", func() {
+		err := CheckNoMPIJobsExist(context.Background(), newDiscovery(),
+			dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+		Expect(err).To(BeNil())
+
+		err = CheckNoMPIJobsExist(context.Background(), newDiscovery("v2beta1"),
+			dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+		Expect(err).To(BeNil())
+
+		err = CheckNoMPIJobsExist(context.Background(), newDiscovery("v2beta1"),
+			dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newMPIJobObject("v2beta1", "job")))
+		Expect(err).NotTo(BeNil())
+		jobsExist, ok := err.(*MPIJobsExistError)
+		Expect(ok).To(BeTrue())
+		Expect(jobsExist.Jobs).To(Equal([]string{"default/job"}))
+	})
+
+	It("should find MPIJobs on clusters which serve only kubeflow.org/v1", func() {
+		err := CheckNoMPIJobsExist(context.Background(), newDiscovery("v1"),
+			dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newMPIJobObject("v1", "job")))
+		Expect(err).NotTo(BeNil())
+		jobsExist, ok := err.(*MPIJobsExistError)
+		Expect(ok).To(BeTrue())
+		Expect(jobsExist.Jobs).To(Equal([]string{"default/job"}))
+
+		// versions which don't serve MPIJobs are skipped
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newMPIJobObject("v1", "job"))
+		dynamicClient.PrependReactor("list", "mpijobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetResource().Version != "v2beta1" {
+				return false, nil, nil
+			}
+			return true, nil, apierrors.NewNotFound(action.GetResource().GroupResource(), "")
+		})
+		err = CheckNoMPIJobsExist(context.Background(), newDiscovery("v2beta1", "v1"), dynamicClient)
+		Expect(err).To(BeAssignableToTypeOf(&MPIJobsExistError{}))
+	})
+
+	It("should delete all objects of the manifest in reverse order", func() {
+		dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+		var deleted []string
+		dynamicClient.PrependReactor("delete", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			deleteAction := action.(k8stesting.DeleteAction)
+			if deleteAction.GetResource().Resource == "serviceaccounts" {
+				return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "serviceaccounts"},
+					deleteAction.GetName())
+			}
+			deleted = append(deleted, deleteAction.GetResource().Resource+"/"+deleteAction.GetName())
+			return true, nil, nil
+		})
+		results, err := DeleteManifest(context.Background(), dynamicClient,
+			newManifestRESTMapper(), []byte(mpiOperatorYaml))
+		Expect(err).To(BeNil())
+		Expect(results).To(HaveLen(9))
+		Expect(results[0].Kind).To(Equal("Deployment"))
+		Expect(results[0].Action).To(Equal(ActionDeleted))
+		Expect(results[7].Kind).To(Equal("CustomResourceDefinition"))
+		Expect(results[6].Action).To(Equal(ActionNotFound))
+		Expect(deleted).To(HaveLen(8))
+		Expect(deleted[len(deleted)-1]).To(Equal("namespaces/mpi-operator"))
+	})
+
+})